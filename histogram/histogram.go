@@ -0,0 +1,336 @@
+// Package histogram holds histo's data model: values recorded under facet
+// keys in a Histogram, with the bucketing and summary statistics the views
+// are drawn from. It has no terminal dependencies, so other Go tools can
+// embed it.
+package histogram
+
+import (
+	"math"
+	"sort"
+)
+
+// -------------------------
+// Statistics
+// -------------------------
+
+// Mean returns the mean of a slice of float64.
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0.0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// MeanStdev returns the mean and standard deviation of a slice of float64:
+// the population standard deviation (dividing by n), or with sample the
+// sample standard deviation (dividing by n-1), which is zero for a single
+// value. Both are zero for an empty slice.
+func MeanStdev(values []float64, sample bool) (mean, stdev float64) {
+	if len(values) == 0 {
+		return 0.0, 0.0
+	}
+	mean = Mean(values)
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	n := len(values)
+	if sample {
+		if n == 1 {
+			return mean, 0
+		}
+		n--
+	}
+	return mean, math.Sqrt(variance / float64(n))
+}
+
+// Percentile returns the p-th percentile (0-100) of an ascending sorted slice,
+// interpolating linearly between the closest ranks.
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0.0
+	}
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower < 0 {
+		return sorted[0]
+	}
+	if upper >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	frac := rank - float64(lower)
+	return sorted[lower] + frac*(sorted[upper]-sorted[lower])
+}
+
+// Stats summarizes a set of values.
+type Stats struct {
+	Count       int
+	Mean, Stdev float64
+	Min, Max    float64
+	// Sorted holds the values in ascending order. It may be shared, so it
+	// must not be modified.
+	Sorted []float64
+}
+
+// Summarize returns the Stats of values, with the sample standard deviation
+// if sample is set (see MeanStdev). values itself is left unsorted.
+func Summarize(values []float64, sample bool) Stats {
+	s := Stats{Count: len(values)}
+	if len(values) == 0 {
+		return s
+	}
+	s.Sorted = append([]float64(nil), values...)
+	sort.Float64s(s.Sorted)
+	s.Mean, s.Stdev = MeanStdev(s.Sorted, sample)
+	s.Min, s.Max = s.Sorted[0], s.Sorted[len(s.Sorted)-1]
+	return s
+}
+
+// Percentile returns the p-th percentile (0-100) of the values.
+func (s Stats) Percentile(p float64) float64 {
+	return Percentile(s.Sorted, p)
+}
+
+// -------------------------
+// Binning
+// -------------------------
+
+// Binning describes how the range [Min, Max] is divided into Count bins.
+// With Log set, bin edges are spaced geometrically; non-positive ranges are
+// shifted so that the smallest value maps to 1 before taking logarithms.
+// A quantile binning (NewQuantileBinning) has explicit edges instead.
+type Binning struct {
+	Min, Max float64
+	Count    int
+	Log      bool
+	shift    float64
+	edges    []float64
+}
+
+// NewBinning returns a binning of [gmin, gmax] into binCount bins.
+func NewBinning(gmin, gmax float64, binCount int, logScale bool) Binning {
+	b := Binning{Min: gmin, Max: gmax, Count: binCount, Log: logScale}
+	if logScale && gmin <= 0 {
+		b.shift = 1 - gmin
+	}
+	return b
+}
+
+// NewZeroAlignedBinning returns an equal-width binning covering [gmin, gmax]
+// in at most binCount bins, with the range widened to whole bins so that zero
+// falls on a bin edge. Data straddling zero is then split cleanly by sign.
+func NewZeroAlignedBinning(gmin, gmax float64, binCount int) Binning {
+	if binCount < 2 || gmax <= gmin {
+		return NewBinning(gmin, gmax, binCount, false)
+	}
+	// Widening each end by up to a bin adds at most one bin, so start from
+	// one bin fewer
+	step := (gmax - gmin) / float64(binCount-1)
+	lo := math.Floor(gmin/step) * step
+	hi := math.Ceil(gmax/step) * step
+	count := int(math.Round((hi - lo) / step))
+	return NewBinning(lo, hi, count, false)
+}
+
+// NewQuantileBinning returns a binning of an ascending sorted slice into
+// binCount bins holding roughly equal numbers of values, with edges at its
+// quantiles. Edges that coincide because of repeated values are merged, so
+// there may be fewer than binCount bins.
+func NewQuantileBinning(sorted []float64, binCount int) Binning {
+	if len(sorted) == 0 {
+		return NewBinning(0, 0, binCount, false)
+	}
+	edges := []float64{sorted[0]}
+	for i := 1; i <= binCount; i++ {
+		edge := Percentile(sorted, 100*float64(i)/float64(binCount))
+		if edge > edges[len(edges)-1] {
+			edges = append(edges, edge)
+		}
+	}
+	if len(edges) == 1 {
+		// A single repeated value: nothing to divide
+		return NewBinning(sorted[0], sorted[0], binCount, false)
+	}
+	return Binning{Min: edges[0], Max: edges[len(edges)-1], Count: len(edges) - 1, edges: edges}
+}
+
+// Variable reports whether the bins have differing widths, as quantile bins do.
+func (b Binning) Variable() bool {
+	return b.edges != nil
+}
+
+// Width returns the width of bin i.
+func (b Binning) Width(i int) float64 {
+	return b.Edge(i+1) - b.Edge(i)
+}
+
+// Index returns the bin that v falls into, clamped to [0, Count-1].
+func (b Binning) Index(v float64) int {
+	if b.Max == b.Min {
+		return 0
+	}
+	if b.edges != nil {
+		if !(v >= b.Min) {
+			return 0
+		}
+		// Each bin holds its lower edge; the last also holds Max
+		inner := b.edges[1:b.Count]
+		return sort.Search(len(inner), func(j int) bool { return inner[j] > v })
+	}
+	var pos float64
+	if b.Log {
+		lo := math.Log(b.Min + b.shift)
+		hi := math.Log(b.Max + b.shift)
+		pos = (math.Log(v+b.shift) - lo) / (hi - lo) * float64(b.Count)
+	} else {
+		pos = (v - b.Min) / ((b.Max - b.Min) / float64(b.Count))
+	}
+	// Clamp before converting: values outside the range (or NaN, or a log
+	// of a non-positive value) would otherwise convert to an arbitrary int
+	switch {
+	case !(pos >= 0):
+		return 0
+	case pos >= float64(b.Count):
+		return b.Count - 1
+	}
+	return int(pos)
+}
+
+// Outside counts the values below and above the binned range, which Index
+// clamps into the edge bins.
+func (b Binning) Outside(values []float64) (under, over int) {
+	for _, v := range values {
+		switch {
+		case v < b.Min:
+			under++
+		case v > b.Max:
+			over++
+		}
+	}
+	return under, over
+}
+
+// Edge returns the lower edge of bin i; Edge(Count) is the upper bound of the range.
+func (b Binning) Edge(i int) float64 {
+	if b.edges != nil {
+		return b.edges[i]
+	}
+	frac := float64(i) / float64(b.Count)
+	if b.Log {
+		lo := math.Log(b.Min + b.shift)
+		hi := math.Log(b.Max + b.shift)
+		return math.Exp(lo+frac*(hi-lo)) - b.shift
+	}
+	return b.Min + frac*(b.Max-b.Min)
+}
+
+// Label returns the axis label value for bin i: the midpoint for linear bins
+// and the lower edge for log-spaced and quantile bins.
+func (b Binning) Label(i int) float64 {
+	if b.Log || b.edges != nil {
+		return b.Edge(i)
+	}
+	return (b.Edge(i) + b.Edge(i+1)) / 2
+}
+
+// Counts distributes values into the bins.
+func (b Binning) Counts(values []float64) []int {
+	counts := make([]int, b.Count)
+	for _, v := range values {
+		counts[b.Index(v)]++
+	}
+	return counts
+}
+
+// -------------------------
+// Histogram
+// -------------------------
+
+// Histogram records values under the keys of one or more facet columns
+// (1-indexed), as histo does for each input line: the value is added to
+// every column, under that column's key. It is a plain map, so a column's
+// keys, and each key's values in the order they were added, can be read
+// and ranged over directly.
+type Histogram map[int]map[string][]float64
+
+// Add records value under facets[i] in column i+1.
+func (h Histogram) Add(value float64, facets []string) {
+	for i, key := range facets {
+		h.AddTo(i+1, key, value)
+	}
+}
+
+// AddTo records value under key in one column.
+func (h Histogram) AddTo(column int, key string, value float64) {
+	if h[column] == nil {
+		h[column] = make(map[string][]float64)
+	}
+	h[column][key] = append(h[column][key], value)
+}
+
+// Columns returns the facet columns, in ascending order.
+func (h Histogram) Columns() []int {
+	columns := make([]int, 0, len(h))
+	for column := range h {
+		columns = append(columns, column)
+	}
+	sort.Ints(columns)
+	return columns
+}
+
+// Keys returns a column's keys in ascending order.
+func (h Histogram) Keys(column int) []string {
+	keys := make([]string, 0, len(h[column]))
+	for key := range h[column] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Range returns the smallest and largest value recorded, or false if there
+// are none.
+func (h Histogram) Range() (min, max float64, ok bool) {
+	for _, keys := range h {
+		for _, values := range keys {
+			for _, v := range values {
+				if !ok || v < min {
+					min = v
+				}
+				if !ok || v > max {
+					max = v
+				}
+				ok = true
+			}
+		}
+	}
+	return min, max, ok
+}
+
+// Stats summarizes a key's values, with the sample standard deviation if
+// sample is set.
+func (h Histogram) Stats(column int, key string, sample bool) Stats {
+	return Summarize(h[column][key], sample)
+}
+
+// Percentile returns the p-th percentile (0-100) of a key's values.
+func (h Histogram) Percentile(column int, key string, p float64) float64 {
+	return h.Stats(column, key, false).Percentile(p)
+}
+
+// Bins divides the range of a key's values into count bins, log-spaced if
+// logScale is set, and returns the binning with the number of values in
+// each bin.
+func (h Histogram) Bins(column int, key string, count int, logScale bool) (Binning, []int) {
+	s := h.Stats(column, key, false)
+	b := NewBinning(s.Min, s.Max, count, logScale)
+	return b, b.Counts(h[column][key])
+}
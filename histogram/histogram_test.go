@@ -0,0 +1,98 @@
+package histogram
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBinningIndexClamps(t *testing.T) {
+	linear := NewBinning(10, 20, 5, false)
+	logScale := NewBinning(1, 100, 4, true)
+	quantile := NewQuantileBinning([]float64{10, 11, 12, 15, 20}, 4)
+	tests := []struct {
+		name string
+		b    Binning
+		v    float64
+		want int
+	}{
+		{"linear, below min", linear, 5, 0},
+		{"linear, far below min", linear, -1e300, 0},
+		{"linear, NaN", linear, math.NaN(), 0},
+		{"linear, min", linear, 10, 0},
+		{"linear, max", linear, 20, 4},
+		{"linear, above max", linear, 25, 4},
+		{"log, below min", logScale, 0.5, 0},
+		{"log, negative", logScale, -3, 0},
+		{"quantile, below min", quantile, 2, 0},
+		{"quantile, above max", quantile, 30, quantile.Count - 1},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Index(tt.v); got != tt.want {
+			t.Errorf("%s: Index(%g) = %d, want %d", tt.name, tt.v, got, tt.want)
+		}
+	}
+
+	// Values outside the range land in the edge bins rather than panicking
+	counts := linear.Counts([]float64{-50, 5, 15, 99})
+	if want := []int{2, 0, 1, 0, 1}; !equalInts(counts, want) {
+		t.Errorf("Counts = %v, want %v", counts, want)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	values := []float64{4, 1, 3, 2}
+	s := Summarize(values, false)
+	if s.Count != 4 || s.Mean != 2.5 || s.Min != 1 || s.Max != 4 {
+		t.Errorf("Summarize = %+v, want count 4, mean 2.5, range 1-4", s)
+	}
+	if got := s.Percentile(50); got != 2.5 {
+		t.Errorf("Percentile(50) = %g, want 2.5", got)
+	}
+	if values[0] != 4 {
+		t.Errorf("Summarize sorted its argument: %v", values)
+	}
+	if s := Summarize(nil, false); s.Count != 0 || s.Percentile(99) != 0 {
+		t.Errorf("Summarize(nil) = %+v", s)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := Histogram{}
+	h.Add(10, []string{"/a", "sea"})
+	h.Add(30, []string{"/b", "sea"})
+	h.Add(20, []string{"/a", "ams"})
+
+	if got := h.Columns(); !equalInts(got, []int{1, 2}) {
+		t.Errorf("Columns = %v, want [1 2]", got)
+	}
+	if got := h.Keys(2); len(got) != 2 || got[0] != "ams" || got[1] != "sea" {
+		t.Errorf("Keys(2) = %q, want [ams sea]", got)
+	}
+	if min, max, ok := h.Range(); min != 10 || max != 30 || !ok {
+		t.Errorf("Range = %g, %g, %v, want 10, 30, true", min, max, ok)
+	}
+	if s := h.Stats(1, "/a", false); s.Count != 2 || s.Mean != 15 || s.Stdev != 5 {
+		t.Errorf("Stats(1, /a) = %+v, want count 2, mean 15, stdev 5", s)
+	}
+	if got := h.Percentile(2, "sea", 50); got != 20 {
+		t.Errorf("Percentile(2, sea, 50) = %g, want 20", got)
+	}
+	if b, counts := h.Bins(2, "sea", 2, false); b.Min != 10 || b.Max != 30 || !equalInts(counts, []int{1, 1}) {
+		t.Errorf("Bins(2, sea) = %+v, %v, want 10-30 and [1 1]", b, counts)
+	}
+	if _, _, ok := (Histogram{}).Range(); ok {
+		t.Error("an empty Histogram has a range")
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
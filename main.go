@@ -2,17 +2,30 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
 	"math"
+	"math/bits"
+	"net"
+	"net/http"
 	"os"
+	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"text/tabwriter"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/dtkav/histo/histogram"
+	"github.com/mattn/go-runewidth"
 )
 
 // -------------------------
@@ -22,10 +35,25 @@ import (
 // model holds the application state.
 type model struct {
 	// facetsData maps facet column (1-indexed) to a map of facet value → slice of numbers.
-	facetsData map[int]map[string][]float64
+	facetsData histogram.Histogram
 
 	// storedLines stores all input lines for reprocessing when pins change
 	storedLines []string
+	// lineTimes holds the arrival time of each entry in storedLines
+	lineTimes []time.Time
+	// window: if nonzero, only lines that arrived within this duration are kept.
+	window time.Duration
+	// maxLines: if nonzero, only the most recent maxLines lines are kept.
+	maxLines int
+	// keyArrivals holds recent arrival times per facet column and key, used for per-facet rates.
+	keyArrivals map[int]map[string][]time.Time
+	// recent counts arrivals over the last few ticks for the header's current rate.
+	recent rollingRate
+
+	// paused: if true, incoming lines are buffered in storedLines but not processed;
+	// pendingLines counts the buffered lines at the end of storedLines.
+	paused       bool
+	pendingLines int
 
 	totalLogCount int
 	startTime     time.Time
@@ -34,18 +62,131 @@ type model struct {
 	facet int
 	// stats: if true, show summary stats (mean, stdev, count) instead of a full histogram.
 	stats bool
-	// lines receives raw lines from STDIN.
-	lines chan string
+	// barHeight is the requested height of the vertical histogram bars in the single-facet view.
+	barHeight int
+	// logScale: if true, bin edges are spaced geometrically instead of linearly.
+	logScale bool
+	// bars selects the glyphs used to draw vertical histogram bars.
+	bars barStyle
+	// perFacetScale: if true, each single-facet panel uses its own min/max instead of the global range.
+	perFacetScale bool
+	// globalColorScale: if true, all-facets bucket colors are normalized to the
+	// largest bucket across every column instead of per column.
+	globalColorScale bool
+	// density: if true, bins are normalized to each facet's own total (relative frequency).
+	density bool
+	// binCount overrides the number of histogram bins; 0 uses each view's default.
+	binCount int
+	// autoBins: if true, the bin count is chosen from the data (Freedman-Diaconis/Sturges).
+	autoBins bool
+	// equalFreq: if true, bin edges are put at quantiles of the data so bins
+	// hold about the same number of values, and bars show count per unit width.
+	equalFreq bool
+	// zeroBaseline: if true, histograms of data straddling zero get a
+	// baseline at zero, with the negative bins drawn apart.
+	zeroBaseline bool
+	// markPercentile, if positive, is the percentile whose bin is marked on
+	// each key's row in the all-facets view.
+	markPercentile float64
+	// minCount hides keys with fewer values than it, unless they're pinned;
+	// with showSparse they are shown, dimmed, instead.
+	minCount   int
+	showSparse bool
+	// showExtremes annotates the header with the largest and smallest values
+	// and the facet keys they were recorded under.
+	showExtremes bool
+	// emptyCell is drawn for empty buckets in the all-facets view, and
+	// tightCells narrows its buckets from five columns to two.
+	emptyCell  string
+	tightCells bool
+	// yAxis: if true, vertical histograms are drawn with count tick labels on the left.
+	yAxis bool
+	// marker selects which central-tendency markers are drawn above vertical histograms.
+	marker markerMode
+	// compact: if true, the multi-facet view renders one sparkline row per key.
+	compact bool
+	// boxPlot: if true, single-facet panels show a box plot instead of a histogram or stats.
+	boxPlot bool
+	// horizontal: if true, single-facet panels draw each bin as a labeled
+	// row, like the string histogram, instead of vertical bars.
+	horizontal bool
+	// stacked: if true, the single-facet view is one histogram with each bin's
+	// bar split into per-key segments.
+	stacked bool
+	// heatmap: if true, the single-facet view is a key × bin heatmap.
+	heatmap bool
+	// ascii: if true, output uses only ASCII characters and no color styling.
+	ascii bool
+	// noColor: if true, output has no color styling but keeps Unicode glyphs
+	// (set by NO_COLOR and -no-color, and implied by ascii).
+	noColor bool
+	// precision is the number of decimal places for displayed values; negative
+	// keeps each display's default.
+	precision int
+	// palette selects the color ramp for bucket intensities in the all-facets view.
+	palette palette
+	// markers are the glyphs marking pinned, excluded and active keys.
+	markers markers
+	// hideLegend hides the color legend under the all-facets view.
+	hideLegend bool
+	// reverseColors flips the ramp so high counts get its low end.
+	reverseColors bool
+
+	// Sorting: sortMode orders facet keys; sortReverse flips the order.
+	sortMode    sortMode
+	sortReverse bool
+
+	// Search: searchQuery hides facet keys that don't contain it (case-insensitive);
+	// searching is true while the search prompt is accepting input.
+	searchQuery string
+	searching   bool
+	// input is the source of raw lines; nil means os.Stdin.
+	input io.Reader
+	// inputName names the input file in the waiting message.
+	inputName string
+	// lines receives parsed lines from input; it is set to nil once input is exhausted.
+	lines chan parsedLine
+	// panics receives a panic from the input goroutines (see reportPanic).
+	panics chan goroutinePanic
+	// parseWorkers is the number of goroutines parsing input; 1 parses on the reader.
+	parseWorkers int
+	// header: if true, the first input line is a header row and is skipped
+	// before parsing, so it is never counted as a value.
+	header bool
+	// delimiter separates the columns of an input line (-delimiter).
+	delimiter string
+	// valueColumn is the input column (1-indexed) holding the value; the
+	// other columns are the facet columns, numbered from 1 in input order.
+	valueColumn int
+	// percentiles are reported by the footer, -summary, -influx and -statsd.
+	percentiles []float64
 
 	// Window dimensions.
 	winWidth, winHeight int
+	// maxWidth, if positive, caps the width the views are laid out for.
+	maxWidth int
 	// scrollOffset tracks how far the content has been scrolled.
 	scrollOffset int
+	// facetPlaces remembers where each facet column was left, restored when
+	// a/d switches back to it.
+	facetPlaces map[int]facetPlace
 
 	// For non-float values in the first column
 	stringValues map[string]int
+	// naTokens are -na sentinels meaning "no value". In the value column they
+	// are only tallied, in missingCount; in facet columns they become nullKey.
+	naTokens     map[string]bool
+	missingCount int
+	// valueScale transforms each value as it's read (-scale); values it
+	// can't transform, like non-positive ones under a log, are only tallied,
+	// in scaleSkipped.
+	valueScale   valueScale
+	scaleSkipped int
 	// countStrings: if true, count occurrences of non-float strings in the first column
 	countStrings bool
+	// topStrings, if positive, limits the string histogram to its most
+	// common topStrings values.
+	topStrings int
 
 	// Navigation
 	activeFacet     string
@@ -58,15 +199,357 @@ type model struct {
 	gridRows    int
 
 	// Pinning feature
-	pinnedFacets       map[string]bool              // key: facet value, value: true if pinned
-	pinnedFacetsColumn map[string]int               // key: facet value, value: column index (1-indexed)
-	filteredData       map[int]map[string][]float64 // filtered data based on pins
-	isFiltered         bool                         // true if at least one facet is pinned
+	pinnedFacets       map[string]bool     // key: facet value, value: true if pinned
+	pinnedFacetsColumn map[string]int      // key: facet value, value: column index (1-indexed)
+	filteredData       histogram.Histogram // filtered data based on pins
+	filteredLines      []string            // processed lines that pass the current pins, in arrival order
+	isFiltered         bool                // true if at least one facet is pinned or excluded
+
+	// Exclude pins: rows whose column matches an excluded value are dropped from filtered data
+	excludedFacets       map[string]bool // key: facet value, value: true if excluded
+	excludedFacetsColumn map[string]int  // key: facet value, value: column index (1-indexed)
+
+	// pinsFile is where pins are saved and loaded; pinsValidated is set once
+	// loaded pins have been checked against the columns present in the data.
+	pinsFile      string
+	pinsValidated bool
+
+	// Compare mode: compareMark is the first key marked with m; once a second
+	// key is marked, compareWith is set and the two are overlaid.
+	compareMark facetRef
+	compareWith facetRef
+
+	// prom, if set, serves the data as Prometheus metrics; its snapshot is
+	// refreshed on each tick.
+	prom *promExporter
+
+	// statsd, if set, periodically sends per-key gauges to a StatsD server.
+	statsd *statsdEmitter
+
+	// influx, if set, periodically writes InfluxDB line protocol.
+	influx *influxWriter
+
+	// ewmaAlpha, if positive, replaces the displayed mean and stdev with
+	// exponentially weighted ones; ewma and filteredEwma hold them per key
+	// for facetsData and filteredData.
+	ewmaAlpha    float64
+	ewma         map[int]map[string]*ewmaStat
+	filteredEwma map[int]map[string]*ewmaStat
+
+	// exportPath is where e (and quitting) writes a JSON snapshot.
+	exportPath string
+	// svgPath is where S writes the current view as an SVG image.
+	svgPath string
+	// baseline is a snapshot loaded with -baseline; showDiff replaces the
+	// content with a diff against it.
+	baseline *snapshot
+	showDiff bool
+
+	// distinctColumn, if positive, is a facet column whose keys are only
+	// counted, in the distinct (and filteredDistinct) sketch, instead of
+	// getting per-key histograms.
+	distinctColumn   int
+	distinct         *hyperLogLog
+	filteredDistinct *hyperLogLog
+
+	// ignoredColumns are facet columns (1-indexed, as in the input) that
+	// never become facets. The remaining columns keep their input positions.
+	ignoredColumns map[int]bool
+
+	// timeColumn, if positive, is a facet column of timestamps, parsed with
+	// timeLayout (or "unix" for epoch seconds), whose keys are the start of
+	// their timeBucket instead.
+	timeColumn int
+	timeLayout string
+	timeBucket time.Duration
+
+	// cross, if set, names two facet columns whose keys are joined as
+	// "a|b" into a virtual column, crossColumn, numbered one past the
+	// first line's last column. crossKeys counts the stored lines of each
+	// combination kept apart, up to crossMax of them; crossOther counts
+	// those of the combinations that share crossOtherKey.
+	cross       [2]int
+	crossColumn int
+	crossKeys   map[string]int
+	crossOther  map[string]int
+	crossMax    int
+
+	// geomean adds the geometric mean to the stats views.
+	geomean bool
+	// sampleStdev: if true, standard deviations divide by n-1 instead of n.
+	sampleStdev bool
+	// confidence adds a 95% confidence interval for the mean to the stats views.
+	confidence bool
+
+	// trim, if positive, adds a mean that ignores the lowest and highest
+	// trim percent of values to the stats views.
+	trim float64
+
+	// outliers: if true, bins holding values beyond Tukey's fences are
+	// highlighted and the stats include an outlier count.
+	outliers bool
+
+	// showOverflow: if true, histograms show how many values fell outside
+	// the binned range instead of silently clamping them into the edge bins.
+	showOverflow bool
+
+	// keys binds keys to actions; nil means the default bindings.
+	keys *keymap
+
+	// showHelp: if true, the help overlay replaces the content.
+	showHelp bool
+
+	// statusMessage is a transient notice shown in the header (e.g. save results).
+	statusMessage string
+
+	// dataVersion is bumped whenever facetsData or filteredData changes; caches
+	// derived from the data are only valid for the version they were built at.
+	dataVersion int
+	// sortedKeys caches sorted facet keys; it is a pointer so that renders on
+	// copies of the model share it.
+	sortedKeys *sortedKeyCache
+	// rangeCache caches globalRange, shared across model copies like sortedKeys.
+	rangeCache *globalRangeCache
+	// statsCache caches keyStats, shared across model copies like sortedKeys.
+	statsCache *statsCache
+	// layout records where keys were drawn by the last render, for mouse
+	// hit-testing; shared across model copies like sortedKeys.
+	layout *screenLayout
+	// stableOrder, set by -stable-order, holds each column's key order so
+	// panels don't move as the data changes; shared like sortedKeys.
+	stableOrder *keyOrder
 }
 
+// keyOrder fixes the order of each facet column's keys: keys keep the
+// position they were first sorted into, and new keys are added at the end.
+type keyOrder struct {
+	orders map[keyOrderRef][]string
+}
+
+// keyOrderRef identifies one column's order in the full or filtered data.
+type keyOrderRef struct {
+	facet    int
+	filtered bool
+}
+
+// apply returns sorted rearranged into the column's fixed order: known keys
+// in their previous order, then new keys in sorted order. Keys no longer
+// present are dropped.
+func (o *keyOrder) apply(ref keyOrderRef, sorted []string) []string {
+	if o.orders == nil {
+		o.orders = make(map[keyOrderRef][]string)
+	}
+	present := make(map[string]bool, len(sorted))
+	for _, key := range sorted {
+		present[key] = true
+	}
+	keys := make([]string, 0, len(sorted))
+	known := make(map[string]bool, len(sorted))
+	for _, key := range o.orders[ref] {
+		if present[key] {
+			keys = append(keys, key)
+			known[key] = true
+		}
+	}
+	for _, key := range sorted {
+		if !known[key] {
+			keys = append(keys, key)
+		}
+	}
+	o.orders[ref] = keys
+	return keys
+}
+
+// reset forgets the fixed orders, so the next render sorts afresh.
+func (o *keyOrder) reset() {
+	o.orders = nil
+}
+
+// rateTicks is how many ticks the rolling rate in the header spans: five
+// seconds at the 500ms tick.
+const rateTicks = 10
+
+// rollingRate counts the lines that arrived in each of the last rateTicks
+// ticks, so the header can show the current rate as well as the average.
+type rollingRate struct {
+	counts []int
+	times  []time.Time
+}
+
+// add records n lines arriving in the tick ending at now.
+func (r *rollingRate) add(now time.Time, n int) {
+	r.counts = append(r.counts, n)
+	r.times = append(r.times, now)
+	if len(r.counts) > rateTicks+1 {
+		r.counts = r.counts[1:]
+		r.times = r.times[1:]
+	}
+}
+
+// rate returns the lines per second over the recorded ticks, or false until
+// there are two ticks to measure between.
+func (r rollingRate) rate() (float64, bool) {
+	if len(r.times) < 2 {
+		return 0, false
+	}
+	// The first tick only marks the start of the span
+	lines := 0
+	for _, n := range r.counts[1:] {
+		lines += n
+	}
+	span := r.times[len(r.times)-1].Sub(r.times[0]).Seconds()
+	if span <= 0 {
+		return 0, false
+	}
+	return float64(lines) / span, true
+}
+
+// sortedKeyCache memoizes getSortedFacetKeys until the data version changes.
+type sortedKeyCache struct {
+	version int
+	entries map[sortedKeyCacheKey][]string
+}
+
+// statsCache memoizes keyStats and allValues until the data version changes.
+// keyStats entries are keyed by the slice they summarize: the data's slices
+// are only appended to or resliced, which changes the key, and the key's
+// pointer keeps a slice's memory from being reused while it is cached.
+type statsCache struct {
+	version int
+	entries map[statsCacheKey]histogram.Stats
+	// all holds allValues for each data source, indexed by whether it is the
+	// filtered one, so that its keyStats are cached too.
+	all [2][]float64
+	// snapshots holds renderDiff's snapshot of each data source.
+	snapshots [2]*snapshot
+}
+
+// statsCacheKey identifies a slice by its first element and length.
+type statsCacheKey struct {
+	first *float64
+	n     int
+}
+
+// sync empties the cache if the data has changed since it was filled.
+func (c *statsCache) sync(version int) {
+	if c.entries == nil || c.version != version {
+		c.entries = make(map[statsCacheKey]histogram.Stats)
+		c.all = [2][]float64{}
+		c.snapshots = [2]*snapshot{}
+		c.version = version
+	}
+}
+
+// globalRangeCache memoizes globalStats for each data source, indexed by
+// whether it is the filtered one. Lines added to a source are folded into its
+// entry as they arrive (see addedLine); any other change to the data leaves
+// the entries behind the data version, and they are recomputed.
+type globalRangeCache struct {
+	entries [2]globalRangeEntry
+}
+
+// globalRangeEntry holds globalStats for one data source as of version.
+type globalRangeEntry struct {
+	valid   bool
+	version int
+	// lowest is the lowest facet column, which the sum is taken over.
+	lowest     int
+	gmin, gmax float64
+	sum        float64
+	ok         bool
+	// minKeys and maxKeys are the facet keys, by column, of a line holding
+	// gmin and of one holding gmax.
+	minKeys, maxKeys map[int]string
+}
+
+// add folds in the value of a line whose lowest stored facet column is
+// column (0 if it wasn't stored). It reports false if the entry has to be
+// recomputed instead, because the line starts a new lowest column.
+func (e *globalRangeEntry) add(value float64, column int) bool {
+	switch {
+	case column == 0:
+		return true
+	case !e.ok:
+		e.gmin, e.gmax, e.sum, e.lowest, e.ok = value, value, value, column, true
+		return true
+	case column < e.lowest:
+		return false
+	}
+	e.gmin = math.Min(e.gmin, value)
+	e.gmax = math.Max(e.gmax, value)
+	if column == e.lowest {
+		e.sum += value
+	}
+	return true
+}
+
+// sortedKeyCacheKey identifies one sorted key list.
+type sortedKeyCacheKey struct {
+	facet    int
+	filtered bool
+	mode     sortMode
+	reverse  bool
+}
+
+// minWindowWidth and minWindowHeight are the smallest terminal size that is
+// rendered; below it View shows a message instead.
+const (
+	minWindowWidth  = 40
+	minWindowHeight = 10
+)
+
+// screenLayout records where facet keys were drawn in the rendered content.
+type screenLayout struct {
+	// contentTop is the screen row of the first visible content line, and
+	// scroll the content line shown there.
+	contentTop, scroll int
+	regions            []keyRegion
+}
+
+// keyRegion is the area of the content, in lines and cells (bottom and right
+// exclusive), occupied by one facet key.
+type keyRegion struct {
+	key                      string
+	top, bottom, left, right int
+}
+
+// record notes that key was drawn in the given content area; it is a no-op
+// on a nil layout.
+func (l *screenLayout) record(key string, top, bottom, left, right int) {
+	if l != nil {
+		l.regions = append(l.regions, keyRegion{key, top, bottom, left, right})
+	}
+}
+
+// keyAt returns the key drawn at screen position (x, y), if any.
+func (l *screenLayout) keyAt(x, y int) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+	line := y - l.contentTop + l.scroll
+	for _, r := range l.regions {
+		if line >= r.top && line < r.bottom && x >= r.left && x < r.right {
+			return r.key, true
+		}
+	}
+	return "", false
+}
+
+// facetRef identifies a facet key within its column (1-indexed).
+type facetRef struct {
+	column int
+	key    string
+}
+
+// keyRateWindow is the rolling window over which per-facet rates are measured.
+const keyRateWindow = 10 * time.Second
+
 // tickMsg is used for periodic updates.
 type tickMsg struct{}
 
+// statusMsg sets the status message from outside the Update goroutine.
+type statusMsg string
+
 // panelStyle is a Lip Gloss style for panels.
 var panelStyle = lipgloss.NewStyle().
 	Border(lipgloss.RoundedBorder()).
@@ -104,6 +587,169 @@ var activePinnedPanelStyle = lipgloss.NewStyle().
 	Padding(1, 2).
 	Margin(1)
 
+// asciiBorder is a plain-ASCII panel border for dumb terminals.
+var asciiBorder = lipgloss.Border{
+	Top: "-", Bottom: "-", Left: "|", Right: "|",
+	TopLeft: "+", TopRight: "+", BottomLeft: "+", BottomRight: "+",
+}
+
+// asciiActiveBorder marks the currently selected panel in ASCII mode.
+var asciiActiveBorder = lipgloss.Border{
+	Top: "=", Bottom: "=", Left: "#", Right: "#",
+	TopLeft: "#", TopRight: "#", BottomLeft: "#", BottomRight: "#",
+}
+
+// asciiBoxReplacer maps the box-drawing characters used by renderBoxPlot to ASCII.
+var asciiBoxReplacer = strings.NewReplacer(
+	"─", "-", "│", "|", "├", "|", "┤", "|",
+	"┌", "+", "┐", "+", "└", "+", "┘", "+", "┬", "+", "┴", "+",
+)
+
+// asciiRamp holds ASCII intensity glyphs from low to high, used in place of
+// colored squares and eighth blocks.
+var asciiRamp = []string{" ", ".", ":", "-", "=", "+", "*", "#", "@"}
+
+// shadeRamp replaces bucket colors when color is disabled but Unicode is available.
+var shadeRamp = []string{" ", "░", "▒", "▓", "█"}
+
+// intensityRamp returns the glyphs that stand in for bucket colors, or nil
+// when colors are used.
+func (m model) intensityRamp() []string {
+	switch {
+	case m.ascii:
+		return asciiRamp
+	case m.noColor:
+		return shadeRamp
+	}
+	return nil
+}
+
+// cellWidth returns the width in columns of an all-facets bucket: the cell
+// itself and the gap after it, which holds the outlier mark.
+func (m model) cellWidth() int {
+	if m.tightCells {
+		return 2
+	}
+	return 5
+}
+
+// intensity maps a normalized count onto the color ramp, flipped end to end
+// with -reverse-colors.
+func (m model) intensity(normalized float64) float64 {
+	if m.reverseColors {
+		return 1 - normalized
+	}
+	return normalized
+}
+
+// cellIntensity returns the intensity of a bucket holding count values, out
+// of scale in the largest one. Counts are log scaled, log(1+count) so that a
+// count of 1 isn't 0, for a better dynamic range.
+func (m model) cellIntensity(count, scale int) float64 {
+	return m.intensity(math.Log1p(float64(count)) / math.Log1p(float64(scale)))
+}
+
+// cellScale returns the count a key's buckets are colored against: the
+// largest bucket of its column (or of every column), or in density mode its
+// own largest bucket, so intensity reflects the shape of the distribution,
+// not its volume.
+func (m model) cellScale(columnMax int, counts []int) int {
+	if !m.density {
+		return columnMax
+	}
+	scale := 0
+	for _, count := range counts {
+		scale = max(scale, count)
+	}
+	return scale
+}
+
+// panelStyleFor returns the panel style for a facet given its active and pinned state.
+func (m model) panelStyleFor(active, pinned bool) lipgloss.Style {
+	if m.ascii {
+		style := lipgloss.NewStyle().Border(asciiBorder).Padding(1, 2).Margin(1)
+		if active {
+			style = style.Border(asciiActiveBorder)
+		}
+		return style
+	}
+	if m.noColor {
+		// Without color the border shape alone marks the panel state
+		style := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(1, 2).Margin(1)
+		switch {
+		case active:
+			style = style.Border(lipgloss.ThickBorder())
+		case pinned:
+			style = style.Border(lipgloss.DoubleBorder())
+		}
+		return style
+	}
+	switch {
+	case active && pinned:
+		return activePinnedPanelStyle
+	case active:
+		return activePanelStyle
+	case pinned:
+		return pinnedPanelStyle
+	}
+	return panelStyle
+}
+
+// markers are the glyphs drawn before pinned and excluded keys, and before
+// the active key when there is no color to highlight it with.
+type markers struct {
+	pin, exclude, active string
+}
+
+// newMarkers returns the markers, filling in defaults for those left empty:
+// emoji, or ASCII with noEmoji. active must be a single cell wide, since the
+// views leave a fixed two-cell lead for it.
+func newMarkers(pin, exclude, active string, noEmoji bool) (markers, error) {
+	mk := markers{pin: "📌", exclude: "🚫", active: ">"}
+	if noEmoji {
+		mk.pin, mk.exclude = "*", "!"
+	}
+	if pin != "" {
+		mk.pin = pin
+	}
+	if exclude != "" {
+		mk.exclude = exclude
+	}
+	if active != "" {
+		mk.active = active
+	}
+	if runewidth.StringWidth(mk.active) != 1 {
+		return mk, fmt.Errorf("-active-marker must be one cell wide, got %q", mk.active)
+	}
+	return mk, nil
+}
+
+// pinPrefix returns the marker drawn before pinned facet keys and its display width.
+func (m model) pinPrefix() (string, int) {
+	return m.markers.pin + " ", runewidth.StringWidth(m.markers.pin) + 1
+}
+
+// activeLead returns the two-cell lead drawn before a key in uncolored
+// views, holding the active marker for the active key.
+func (m model) activeLead(active bool) string {
+	if active {
+		return m.markers.active + " "
+	}
+	return "  "
+}
+
+// keyMarker returns the marker drawn before a facet key (pinned or excluded)
+// and its display width; unmarked keys get an empty marker.
+func (m model) keyMarker(key string) (string, int) {
+	switch {
+	case m.pinnedFacets[key]:
+		return m.pinPrefix()
+	case m.excludedFacets[key]:
+		return m.markers.exclude + " ", runewidth.StringWidth(m.markers.exclude) + 1
+	}
+	return "", 0
+}
+
 // -------------------------
 // Commands and Init
 // -------------------------
@@ -115,16 +761,170 @@ func tickCmd() tea.Cmd {
 	})
 }
 
-// Init starts the background goroutine that reads STDIN.
+// Init starts the background goroutine that reads the input (STDIN by default).
 func (m *model) Init() tea.Cmd {
+	input := m.input
+	if input == nil {
+		input = os.Stdin
+	}
+	go m.readInput(input)
+	return tickCmd()
+}
+
+// openInput opens the input file at path. A named pipe (FIFO) is opened by
+// the reader on first use rather than here, since opening one blocks until a
+// writer connects.
+func openInput(path string) (io.Reader, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeNamedPipe != 0 {
+		return &fifoReader{path: path}, nil
+	}
+	return os.Open(path)
+}
+
+// fifoReader reads a named pipe across writer reconnects. When the last
+// writer closes the pipe it reads EOF, so the pipe is reopened, waiting for
+// the next writer, instead of ending the input. It never returns io.EOF.
+type fifoReader struct {
+	path string
+	file *os.File
+	// last is the last byte read, to end a line cut off by a disconnect.
+	last byte
+}
+
+func (r *fifoReader) Read(p []byte) (int, error) {
+	for {
+		if r.file == nil {
+			// A blocking open, so it waits for a writer rather than failing
+			file, err := os.OpenFile(r.path, os.O_RDONLY, 0)
+			if err != nil {
+				return 0, err
+			}
+			r.file = file
+		}
+		n, err := r.file.Read(p)
+		if n > 0 {
+			r.last = p[n-1]
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		// The writer went away: reopen for the next one, but first end a
+		// partial line so it isn't joined to the next writer's first line
+		r.file.Close()
+		r.file = nil
+		if r.last != '\n' && r.last != 0 && len(p) > 0 {
+			r.last = '\n'
+			p[0] = '\n'
+			return 1, nil
+		}
+	}
+}
+
+// parseBatchSize caps how many lines a parse worker handles at once.
+const parseBatchSize = 256
+
+// readInput parses lines from input and sends them to m.lines in input
+// order, closing it at EOF. With more than one parse worker, lines are parsed
+// in batches by a pool; a batch takes whatever has already been read, so a
+// slow producer's lines are not held back waiting for a full batch.
+func (m *model) readInput(input io.Reader) {
+	defer close(m.lines)
+	defer m.reportPanic()
+	scanner := bufio.NewScanner(input)
+	if m.header && !scanner.Scan() {
+		return
+	}
+	if m.parseWorkers <= 1 {
+		for scanner.Scan() {
+			m.lines <- parseLine(scanner.Text(), m.delimiter, m.valueColumn)
+		}
+		return
+	}
+
+	raw := make(chan string, parseBatchSize)
 	go func() {
-		scanner := bufio.NewScanner(os.Stdin)
+		defer m.reportPanic()
 		for scanner.Scan() {
-			m.lines <- scanner.Text()
+			raw <- scanner.Text()
 		}
-		close(m.lines)
+		close(raw)
 	}()
-	return tickCmd()
+
+	// Each batch carries its own result channel; queuing those in read order
+	// lets workers finish out of order while results are delivered in order.
+	type batch struct {
+		lines  []string
+		parsed chan []parsedLine
+	}
+	jobs := make(chan batch)
+	queue := make(chan batch, m.parseWorkers)
+	for i := 0; i < m.parseWorkers; i++ {
+		go func() {
+			defer m.reportPanic()
+			for b := range jobs {
+				parsed := make([]parsedLine, len(b.lines))
+				for j, line := range b.lines {
+					parsed[j] = parseLine(line, m.delimiter, m.valueColumn)
+				}
+				b.parsed <- parsed
+			}
+		}()
+	}
+	go func() {
+		defer m.reportPanic()
+		for line := range raw {
+			b := batch{lines: []string{line}, parsed: make(chan []parsedLine, 1)}
+		fill:
+			for len(b.lines) < parseBatchSize {
+				select {
+				case line, ok := <-raw:
+					if !ok {
+						break fill
+					}
+					b.lines = append(b.lines, line)
+				default:
+					break fill
+				}
+			}
+			queue <- b
+			jobs <- b
+		}
+		close(jobs)
+		close(queue)
+	}()
+
+	for b := range queue {
+		for _, p := range <-b.parsed {
+			m.lines <- p
+		}
+	}
+}
+
+// goroutinePanic is a panic recovered on a background goroutine, with that
+// goroutine's stack.
+type goroutinePanic struct {
+	value interface{}
+	stack []byte
+}
+
+// reportPanic, deferred by a background goroutine, recovers a panic and
+// hands it to Update through m.panics. Update panics with it in turn, so it
+// unwinds through restoreOnPanic like a panic in Update itself instead of
+// killing the program with the terminal still in raw mode.
+func (m *model) reportPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	select {
+	case m.panics <- goroutinePanic{r, debug.Stack()}:
+	default: // another goroutine's panic is already on its way
+	}
 }
 
 // -------------------------
@@ -135,140 +935,352 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
 	case tickMsg:
+		select {
+		case p := <-m.panics:
+			panic(p)
+		default:
+		}
+
 		// Drain any available lines (nonblocking)
+		arrived := 0
 		for {
 			select {
 			case line, ok := <-m.lines:
 				if !ok {
-					break
+					// Input is exhausted; a nil channel is never ready,
+					// so later ticks skip straight to the default case
+					m.lines = nil
+					goto done
 				}
-				m.processLine(line)
+				m.processParsedLine(line)
+				arrived++
 			default:
 				goto done
 			}
 		}
 	done:
+		if !m.pinsValidated && len(m.facetsData) > 0 {
+			m.validatePins()
+		}
+		m.selectStartPin()
+		now := time.Now()
+		m.recent.add(now, arrived)
+		m.evictExpired(now)
+		m.pruneKeyArrivals(now)
+		if m.prom != nil {
+			m.prom.update(m)
+		}
+		if m.statsd != nil && now.Sub(m.statsd.last) >= m.statsd.interval {
+			m.statsd.last = now
+			m.statsd.emit(m)
+		}
+		if m.influx != nil {
+			if err := m.influx.poll(); err != nil {
+				m.statusMessage = fmt.Sprintf("Influx write failed: %v", err)
+			}
+			if now.Sub(m.influx.last) >= m.influx.interval {
+				m.influx.last = now
+				if err := m.influx.write(m.influxLines(now)); err != nil {
+					m.statusMessage = fmt.Sprintf("Influx write failed: %v", err)
+				}
+			}
+		}
 		return m, tickCmd()
 
+	case statusMsg:
+		m.statusMessage = string(msg)
+		return m, nil
+
 	case tea.WindowSizeMsg:
 		m.winWidth = msg.Width
 		m.winHeight = msg.Height
 		return m, nil
 
+	case tea.MouseMsg:
+		return m.updateMouse(msg)
+
 	case tea.KeyMsg:
-		switch msg.String() {
+		// While the search prompt is open, keys edit the query
+		if m.searching {
+			return m.updateSearch(msg)
+		}
+
+		// Ctrl+C always quits, whatever the keymap says
+		key := msg.String()
+		if key == "ctrl+c" {
+			return m, tea.Quit
+		}
+		act, ok := m.keymap().byKey[key]
+		if !ok {
+			return m, nil
+		}
+
+		switch act {
 		// Quit the program.
-		case "ctrl+c", "q":
+		case actionQuit:
 			return m, tea.Quit
 
-		// Switch facets with "a" and "d" keys
-		case "a":
-			if m.facet > 0 {
-				m.facet--
-				// Reset scroll when switching facets.
+		// Open the incremental search prompt
+		case actionSearch:
+			m.searching = true
+			return m, nil
+
+		// Save the current pins to the pins file
+		case actionSavePins:
+			m.savePinsFile()
+			return m, nil
+
+		// Clear all accumulated data
+		case actionClear:
+			m.clearData()
+			return m, nil
+
+		// Pause or resume input ingestion
+		case actionPause:
+			m.togglePause()
+			return m, nil
+
+		// Jump to the first or last facet
+		case actionFirst:
+			m.jumpToFacet(false)
+			return m, nil
+
+		case actionLast:
+			m.jumpToFacet(true)
+			return m, nil
+
+		// Cycle the facet sort order and reverse it
+		case actionSort:
+			m.sortMode = m.sortMode.next()
+			m.resort()
+			return m, nil
+
+		case actionReverse:
+			m.sortReverse = !m.sortReverse
+			m.resort()
+			return m, nil
+
+		// Re-sort a -stable-order view once, then hold the new order
+		case actionResort:
+			m.resort()
+			return m, nil
+
+		case actionHelp:
+			m.showHelp = !m.showHelp
+			m.scrollOffset = 0
+			return m, nil
+
+		// Clear the search filter
+		case actionBack:
+			if m.showHelp {
+				m.showHelp = false
 				m.scrollOffset = 0
-				// Reset active facet
-				m.resetActiveFacet()
+			} else if m.searchQuery != "" {
+				m.setSearchQuery("")
+			}
+			return m, nil
+
+		// Switch facets with "a" and "d" keys
+		case actionPrevFacet:
+			facet := m.facet - 1
+			for facet > 0 && m.ignoredColumns[facet] {
+				facet--
+			}
+			if facet >= 0 {
+				m.switchFacet(facet)
+			}
+			return m, nil
+
+		case actionNextFacet:
+			facet := m.facet + 1
+			for m.ignoredColumns[facet] {
+				facet++
+			}
+			if facet <= m.maxFacet() {
+				m.switchFacet(facet)
 			}
 			return m, nil
 
-		case "d":
-			// Determine maximum facet available.
-			dataSource := m.facetsData
-			if m.isFiltered {
-				dataSource = m.filteredData
+		// Jump straight to a facet column with 1-9
+		case actionFacet1, actionFacet2, actionFacet3, actionFacet4, actionFacet5,
+			actionFacet6, actionFacet7, actionFacet8, actionFacet9:
+			facet := int(act-actionFacet1) + 1
+			if m.ignoredColumns[facet] {
+				m.statusMessage = fmt.Sprintf("Facet column %d is ignored (-ignore-cols)", facet)
+				return m, nil
+			}
+			if !m.hasFacet(facet) {
+				m.statusMessage = fmt.Sprintf("No facet column %d", facet)
+				return m, nil
 			}
+			if facet != m.facet {
+				m.switchFacet(facet)
+			}
+			return m, nil
+
+		// Toggle between global and per-facet axis scaling
+		case actionToggleScale:
+			m.perFacetScale = !m.perFacetScale
+			return m, nil
+
+		// Toggle between per-column and global color normalization
+		case actionToggleColorScale:
+			m.globalColorScale = !m.globalColorScale
+			return m, nil
+
+		// Toggle relative-frequency (density) normalization
+		case actionDensity:
+			m.density = !m.density
+			return m, nil
+
+		// Toggle box-plot rendering of single-facet panels
+		case actionBoxPlot:
+			m.boxPlot = !m.boxPlot
+			return m, nil
+
+		// Toggle horizontal bars in single-facet panels
+		case actionHorizontal:
+			m.horizontal = !m.horizontal
+			return m, nil
+
+		// Write a JSON snapshot of the current statistics
+		case actionExport:
+			m.exportSnapshot()
+			return m, nil
 
-			maxFacet := 0
-			for k := range dataSource {
-				if k > maxFacet {
-					maxFacet = k
+		// Show or hide the all-facets color legend
+		case actionLegend:
+			m.hideLegend = !m.hideLegend
+			return m, nil
+
+		// Flip between histograms and the mean/stdev summary
+		case actionStats:
+			m.stats = !m.stats
+			return m, nil
+
+		// Show or hide the keys under -min-count
+		case actionSparse:
+			m.showSparse = !m.showSparse
+			if !m.showSparse && m.activeFacet != "" {
+				dataSource := m.facetsData
+				if m.isFiltered {
+					dataSource = m.filteredData
+				}
+				if m.sparse(m.activeFacet, dataSource[m.activeFacetColumn()][m.activeFacet]) {
+					m.resetActiveFacet()
 				}
 			}
-			if m.facet == 0 && maxFacet > 0 {
-				m.facet = 1
-				m.scrollOffset = 0
-				m.resetActiveFacet()
-			} else if m.facet < maxFacet {
-				m.facet++
-				m.scrollOffset = 0
-				m.resetActiveFacet()
+			return m, nil
+
+		// Show or hide where the global max and min came from
+		case actionExtremes:
+			m.showExtremes = !m.showExtremes
+			return m, nil
+
+		// Write the current view as an SVG image
+		case actionExportSVG:
+			m.exportSVG()
+			return m, nil
+
+		// Toggle the diff against the baseline snapshot
+		case actionDiff:
+			if m.baseline == nil {
+				m.statusMessage = "No baseline loaded (use -baseline FILE)"
+				return m, nil
 			}
+			m.showDiff = !m.showDiff
+			m.scrollOffset = 0
+			return m, nil
+
+		// Toggle IQR outlier highlighting
+		case actionOutliers:
+			m.outliers = !m.outliers
+			return m, nil
+
+		// Toggle the stacked single-facet histogram
+		case actionStacked:
+			m.stacked = !m.stacked
+			m.heatmap = false
+			m.scrollOffset = 0
+			return m, nil
+
+		// Toggle the key × bin heatmap of the single-facet view
+		case actionHeatmap:
+			m.heatmap = !m.heatmap
+			m.stacked = false
+			m.scrollOffset = 0
 			return m, nil
 
 		// Navigate between histograms with arrow keys
-		case "left":
+		case actionLeft:
 			m.navigateGrid(-1, 0)
 			return m, nil
 
-		case "right":
+		case actionRight:
 			m.navigateGrid(1, 0)
 			return m, nil
 
 		// Reset view to show all facets.
-		case "0":
+		case actionAllFacets:
 			m.facet = 0
 			m.scrollOffset = 0
 			m.resetActiveFacet()
 			return m, nil
 
 		// Scroll content with j/k
-		case "k":
+		case actionScrollUp:
 			m.scrollOffset--
 			if m.scrollOffset < 0 {
 				m.scrollOffset = 0
 			}
 			return m, nil
 
-		case "j":
+		case actionScrollDown:
 			m.scrollOffset++
 			return m, nil
 
+		// Scroll a screen at a time
+		case actionPageUp:
+			m.scrollPage(-1)
+			return m, nil
+
+		case actionPageDown:
+			m.scrollPage(1)
+			return m, nil
+
 		// Navigate between histograms with arrow keys
-		case "up":
+		case actionUp:
 			m.navigateGrid(0, -1)
 			return m, nil
 
-		case "down":
+		case actionDown:
 			m.navigateGrid(0, 1)
 			return m, nil
 
 		// Implement pinning with Enter key
-		case "enter":
-			// Only pin if we have an active facet
+		case actionPin:
+			m.togglePin()
+			return m, nil
+
+		// Mark keys for side-by-side comparison
+		case actionCompare:
+			m.toggleCompareMark()
+			return m, nil
+
+		// Exclude (negative pin) the active facet
+		case actionExclude:
 			if m.activeFacet != "" {
-				// Toggle pin state
-				if m.pinnedFacets[m.activeFacet] {
-					// Unpin this facet
-					delete(m.pinnedFacets, m.activeFacet)
-					delete(m.pinnedFacetsColumn, m.activeFacet)
+				// Adding an exclude only narrows the filter
+				narrowing := !m.excludedFacets[m.activeFacet]
+				if m.excludedFacets[m.activeFacet] {
+					delete(m.excludedFacets, m.activeFacet)
+					delete(m.excludedFacetsColumn, m.activeFacet)
 				} else {
-					// Pin this facet
-					m.pinnedFacets[m.activeFacet] = true
-
-					// Store which column this facet belongs to
-					if m.facet > 0 {
-						// If we're in a single facet view, use that facet number
-						m.pinnedFacetsColumn[m.activeFacet] = m.facet
-					} else {
-						// In the all-facets view, determine the column from our position
-						// For each facet column
-						for facetCol, facetMap := range m.facetsData {
-							if _, exists := facetMap[m.activeFacet]; exists {
-								m.pinnedFacetsColumn[m.activeFacet] = facetCol
-								break
-							}
-						}
-					}
+					m.excludedFacets[m.activeFacet] = true
+					m.excludedFacetsColumn[m.activeFacet] = m.activeFacetColumn()
 				}
 
-				// Check if we need to update filtered status
-				m.isFiltered = len(m.pinnedFacets) > 0
-
-				// If we have pins, regenerate filtered data
-				if m.isFiltered {
-					m.regenerateFilteredData()
-				}
+				m.refreshFilter(narrowing)
 			}
 			return m, nil
 
@@ -281,19 +1293,539 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 }
 
+// updateSearch handles key presses while the search prompt is open.
+func (m *model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		// Escape abandons the search entirely
+		m.searching = false
+		m.setSearchQuery("")
+	case tea.KeyEnter:
+		// Enter keeps the filter and returns to navigation
+		m.searching = false
+	case tea.KeyBackspace:
+		if runes := []rune(m.searchQuery); len(runes) > 0 {
+			m.setSearchQuery(string(runes[:len(runes)-1]))
+		}
+	case tea.KeySpace:
+		m.setSearchQuery(m.searchQuery + " ")
+	case tea.KeyRunes:
+		m.setSearchQuery(m.searchQuery + string(msg.Runes))
+	}
+	return m, nil
+}
+
+// setSearchQuery updates the search filter and moves the selection onto a match.
+func (m *model) setSearchQuery(query string) {
+	m.searchQuery = query
+	m.scrollOffset = 0
+	m.resetActiveFacet()
+}
+
+// visibleFacetKeys returns the sorted keys of a facet column's map, limited to
+// those matching the current search query and, unless shown on demand, those
+// with at least -min-count values. The returned slice may be shared
+// with the cache and must not be modified.
+func (m model) visibleFacetKeys(facet int, facetData map[string][]float64) []string {
+	keys := m.sortedFacetKeys(facet, facetData)
+	hideSparse := m.minCount > 0 && !m.showSparse
+	if m.searchQuery == "" && !hideSparse {
+		return keys
+	}
+
+	query := strings.ToLower(m.searchQuery)
+	matches := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if hideSparse && m.sparse(key, facetData[key]) {
+			continue
+		}
+		if strings.Contains(strings.ToLower(key), query) {
+			matches = append(matches, key)
+		}
+	}
+	return matches
+}
+
+// sparse reports whether a key has fewer values than -min-count. Pinned keys
+// are never sparse, so a pin can't hide itself.
+func (m model) sparse(key string, values []float64) bool {
+	return len(values) < m.minCount && !m.pinnedFacets[key]
+}
+
+// hiddenSparseKeys counts the keys -min-count hides in the columns shown.
+func (m model) hiddenSparseKeys() int {
+	if m.minCount <= 0 || m.showSparse {
+		return 0
+	}
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+	hidden := 0
+	for facet, facetData := range dataSource {
+		if m.facet != 0 && facet != m.facet {
+			continue
+		}
+		for key, values := range facetData {
+			if m.sparse(key, values) {
+				hidden++
+			}
+		}
+	}
+	return hidden
+}
+
+// togglePin pins the active facet, or unpins it if it is already pinned.
+func (m *model) togglePin() {
+	// Only pin if we have an active facet
+	if m.activeFacet == "" {
+		return
+	}
+
+	// Toggle pin state; adding a pin only narrows the filter
+	narrowing := !m.pinnedFacets[m.activeFacet]
+	if m.pinnedFacets[m.activeFacet] {
+		// Unpin this facet
+		delete(m.pinnedFacets, m.activeFacet)
+		delete(m.pinnedFacetsColumn, m.activeFacet)
+	} else {
+		// Pin this facet, remembering which column it belongs to
+		m.pinnedFacets[m.activeFacet] = true
+		m.pinnedFacetsColumn[m.activeFacet] = m.activeFacetColumn()
+	}
+
+	m.refreshFilter(narrowing)
+}
+
+// updateMouse handles mouse input: the wheel scrolls, clicking a facet
+// selects it, and clicking the selected facet again (or right-clicking any
+// facet) toggles its pin.
+func (m *model) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.MouseWheelUp:
+		m.scrollOffset = max(0, m.scrollOffset-1)
+	case tea.MouseWheelDown:
+		m.scrollOffset = min(m.scrollOffset+1, m.maxScrollOffset())
+	case tea.MouseLeft, tea.MouseRight:
+		key, ok := m.layout.keyAt(msg.X, msg.Y)
+		if !ok {
+			break
+		}
+		again := key == m.activeFacet
+		m.activeFacet = key
+		m.updatePositionFromActiveFacet()
+		if again || msg.Type == tea.MouseRight {
+			m.togglePin()
+		}
+	}
+	return m, nil
+}
+
+// toggleCompareMark marks the active key for comparison. Marking a second key
+// enters compare mode; pressing m again (or on the marked key) clears it.
+func (m *model) toggleCompareMark() {
+	active := facetRef{column: m.activeFacetColumn(), key: m.activeFacet}
+	switch {
+	case m.compareWith.key != "" || m.activeFacet == "" || active == m.compareMark:
+		m.compareMark, m.compareWith = facetRef{}, facetRef{}
+	case m.compareMark.key == "":
+		m.compareMark = active
+	default:
+		m.compareWith = active
+		m.scrollOffset = 0
+	}
+}
+
+// activeFacetColumn returns the column (1-indexed) that the active facet belongs to.
+func (m *model) activeFacetColumn() int {
+	// If we're in a single facet view, use that facet number
+	if m.facet > 0 {
+		return m.facet
+	}
+
+	// In the all-facets view, find the column containing the key
+	for facetCol, facetMap := range m.facetsData {
+		if _, exists := facetMap[m.activeFacet]; exists {
+			return facetCol
+		}
+	}
+	return 0
+}
+
+// refreshFilter updates the filtered status after pins or excludes change and
+// regenerates the filtered data if any remain. When narrowing (a pin or
+// exclude was added to an already-filtered view), only the lines that passed
+// the previous filter need to be re-checked.
+func (m *model) refreshFilter(narrowing bool) {
+	wasFiltered := m.isFiltered
+	m.isFiltered = len(m.pinnedFacets) > 0 || len(m.excludedFacets) > 0
+	if !m.isFiltered {
+		m.filteredLines = nil
+		return
+	}
+	if narrowing && wasFiltered {
+		m.narrowFilteredData()
+	} else {
+		m.regenerateFilteredData()
+	}
+}
+
+// pinEntry is the JSON form of a single pin in the pins file.
+type pinEntry struct {
+	Column int    `json:"column"`
+	Value  string `json:"value"`
+}
+
+// savePinsFile writes the current pins to m.pinsFile as JSON.
+func (m *model) savePinsFile() {
+	if m.pinsFile == "" {
+		m.statusMessage = "No pins file set (use -pins-file)"
+		return
+	}
+
+	pins := make([]pinEntry, 0, len(m.pinnedFacets))
+	for value, isPinned := range m.pinnedFacets {
+		if isPinned {
+			pins = append(pins, pinEntry{Column: m.pinnedFacetsColumn[value], Value: value})
+		}
+	}
+	sort.Slice(pins, func(i, j int) bool {
+		if pins[i].Column != pins[j].Column {
+			return pins[i].Column < pins[j].Column
+		}
+		return pins[i].Value < pins[j].Value
+	})
+
+	data, err := json.MarshalIndent(pins, "", "  ")
+	if err == nil {
+		err = os.WriteFile(m.pinsFile, append(data, '\n'), 0o644)
+	}
+	if err != nil {
+		m.statusMessage = fmt.Sprintf("Error saving pins: %v", err)
+		return
+	}
+	m.statusMessage = fmt.Sprintf("Saved %d pins to %s", len(pins), m.pinsFile)
+}
+
+// loadPinsFile reads pins from m.pinsFile, if it exists, and applies them.
+func (m *model) loadPinsFile() error {
+	data, err := os.ReadFile(m.pinsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading pins file: %w", err)
+	}
+
+	var pins []pinEntry
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return fmt.Errorf("parsing pins file %s: %w", m.pinsFile, err)
+	}
+	m.addPins(pins)
+	return nil
+}
+
+// addPins pins each entry's value in its column and refreshes the filter.
+func (m *model) addPins(pins []pinEntry) {
+	for _, pin := range pins {
+		if pin.Column < 1 {
+			continue
+		}
+		m.pinnedFacets[pin.Value] = true
+		m.pinnedFacetsColumn[pin.Value] = pin.Column
+	}
+	m.refreshFilter(false)
+}
+
+// pinFlag collects repeated -pin COLUMN:VALUE flags.
+type pinFlag []pinEntry
+
+func (p *pinFlag) String() string {
+	var pins []string
+	for _, pin := range *p {
+		pins = append(pins, fmt.Sprintf("%d:%s", pin.Column, pin.Value))
+	}
+	return strings.Join(pins, ", ")
+}
+
+func (p *pinFlag) Set(s string) error {
+	colText, value, ok := strings.Cut(s, ":")
+	col, err := strconv.Atoi(colText)
+	if !ok || err != nil || col < 1 {
+		return fmt.Errorf("pin %q is not COLUMN:VALUE", s)
+	}
+	*p = append(*p, pinEntry{Column: col, Value: value})
+	return nil
+}
+
+// selectStartPin makes a pinned key in the displayed column the active
+// facet once it shows up in the data, so -facet and -pin open on it.
+func (m *model) selectStartPin() {
+	if m.facet == 0 || m.activeFacet != "" {
+		return
+	}
+	var found []string
+	for value, col := range m.pinnedFacetsColumn {
+		if _, ok := m.facetsData[col][value]; ok && col == m.facet && m.pinnedFacets[value] {
+			found = append(found, value)
+		}
+	}
+	if len(found) > 0 {
+		sort.Strings(found)
+		m.activeFacet = found[0]
+	}
+}
+
+// configSetting is one flag value from a config file.
+type configSetting struct {
+	line        int
+	name, value string
+}
+
+// config holds what a -config file sets: flag values in file order and
+// key bindings by action name.
+type config struct {
+	path     string
+	settings []configSetting
+	keys     map[string][]string
+}
+
+// loadConfig reads a config file written in a small subset of TOML: one
+// `name = value` per line, where name is a flag name and value is a quoted
+// string, a number, a boolean, or an array of them for a list flag, plus
+// `pins = ["COLUMN:VALUE", ...]` and `keys.ACTION = "KEY"` or
+// `["KEY", ...]`. Blank lines and # comments are ignored.
+func loadConfig(path string) (config, error) {
+	cfg := config{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("reading config: %w", err)
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			return cfg, fmt.Errorf("%s:%d: expected name = value", path, lineNo)
+		}
+		name = strings.TrimSpace(name)
+
+		if name == "pins" {
+			values, rest, err := parseConfigArray(strings.TrimSpace(rest))
+			if err == nil {
+				err = checkConfigTail(rest)
+			}
+			if err != nil {
+				return cfg, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			// Each pin is a -pin flag, so -pin on the command line replaces them
+			for _, v := range values {
+				cfg.settings = append(cfg.settings, configSetting{lineNo, "pin", v})
+			}
+			continue
+		}
+
+		if actionName := strings.TrimPrefix(name, "keys."); actionName != name {
+			rest = strings.TrimSpace(rest)
+			var keys []string
+			var err error
+			if strings.HasPrefix(rest, "[") {
+				keys, rest, err = parseConfigArray(rest)
+			} else {
+				var key string
+				key, rest, err = parseConfigValue(rest)
+				keys = []string{key}
+			}
+			if err == nil {
+				err = checkConfigTail(rest)
+			}
+			if err != nil {
+				return cfg, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			if cfg.keys == nil {
+				cfg.keys = make(map[string][]string)
+			}
+			cfg.keys[actionName] = keys
+			continue
+		}
+
+		rest = strings.TrimSpace(rest)
+		var value string
+		if strings.HasPrefix(rest, "[") {
+			// An array sets a comma-separated list flag, like -percentiles
+			var values []string
+			values, rest, err = parseConfigArray(rest)
+			value = strings.Join(values, ",")
+		} else {
+			value, rest, err = parseConfigValue(rest)
+		}
+		if err == nil {
+			err = checkConfigTail(rest)
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		cfg.settings = append(cfg.settings, configSetting{lineNo, name, value})
+	}
+	return cfg, nil
+}
+
+// parseConfigValue parses a quoted string or a bare number or boolean from
+// the start of s, returning the value and the rest of s.
+func parseConfigValue(s string) (string, string, error) {
+	switch {
+	case s == "":
+		return "", "", fmt.Errorf("missing value")
+	case s[0] == '\'':
+		// Literal string: no escapes
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case s[0] == '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				value, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", "", fmt.Errorf("invalid string %s", s[:i+1])
+				}
+				return value, s[i+1:], nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string")
+	}
+	end := strings.IndexAny(s, " \t#,]")
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], s[end:], nil
+}
+
+// parseConfigArray parses a single-line array of values from the start of s.
+func parseConfigArray(s string) ([]string, string, error) {
+	if !strings.HasPrefix(s, "[") {
+		return nil, "", fmt.Errorf("expected an array")
+	}
+	var values []string
+	s = strings.TrimSpace(s[1:])
+	for !strings.HasPrefix(s, "]") {
+		value, rest, err := parseConfigValue(s)
+		if err != nil {
+			return nil, "", err
+		}
+		values = append(values, value)
+		s = strings.TrimSpace(rest)
+		if strings.HasPrefix(s, ",") {
+			s = strings.TrimSpace(s[1:])
+		} else if !strings.HasPrefix(s, "]") {
+			return nil, "", fmt.Errorf("expected , or ] in array")
+		}
+	}
+	return values, s[1:], nil
+}
+
+// checkConfigTail reports anything but a comment after a value.
+func checkConfigTail(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected %q after value", rest)
+	}
+	return nil
+}
+
+// applyFlags sets each flag named in the config that wasn't given on the
+// command line, so flags override the file.
+func (cfg config) applyFlags() error {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for _, s := range cfg.settings {
+		if s.name == "config" || flag.Lookup(s.name) == nil {
+			return fmt.Errorf("%s:%d: unknown setting %q", cfg.path, s.line, s.name)
+		}
+		if explicit[s.name] {
+			continue
+		}
+		if err := flag.Set(s.name, s.value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", cfg.path, s.line, s.name, err)
+		}
+	}
+	return nil
+}
+
+// validatePins drops pins whose column is not present in the data, noting
+// them in the status message.
+func (m *model) validatePins() {
+	m.pinsValidated = true
+
+	var dropped []string
+	for value := range m.pinnedFacets {
+		col := m.pinnedFacetsColumn[value]
+		if _, ok := m.facetsData[col]; !ok {
+			dropped = append(dropped, fmt.Sprintf("%d:%s", col, value))
+			delete(m.pinnedFacets, value)
+			delete(m.pinnedFacetsColumn, value)
+		}
+	}
+	if len(dropped) == 0 {
+		return
+	}
+
+	sort.Strings(dropped)
+	m.statusMessage = fmt.Sprintf("Warning: dropped pins for missing columns: %s", strings.Join(dropped, ", "))
+	m.refreshFilter(false)
+}
+
 // regenerateFilteredData recreates the filtered dataset based on pinned facets
 func (m *model) regenerateFilteredData() {
+	m.rebuildFilteredData(m.processedLines())
+}
+
+// narrowFilteredData recreates the filtered dataset after the filter became
+// more restrictive. Only lines that passed the previous filter can pass the
+// new one, so this costs O(filtered lines) rather than O(all stored lines).
+func (m *model) narrowFilteredData() {
+	m.rebuildFilteredData(m.filteredLines)
+}
+
+// rebuildFilteredData recreates the filtered dataset from the candidate lines.
+func (m *model) rebuildFilteredData(candidates []string) {
 	// Reset the filtered data structure
-	m.filteredData = make(map[int]map[string][]float64)
+	m.filteredData = make(histogram.Histogram)
+	m.filteredLines = nil
+	m.filteredEwma = nil
+	m.filteredDistinct = nil
+	m.dataVersion++
 
 	// Initialize each facet column in filtered data
 	for facetCol := range m.facetsData {
 		m.filteredData[facetCol] = make(map[string][]float64)
 	}
 
-	// Reprocess all stored lines with the current pin configuration
-	for _, line := range m.storedLines {
-		m.processLineWithFilter(line, true)
+	// Reprocess the candidate lines with the current pin configuration
+	for _, line := range candidates {
+		m.processFilteredLine(parseLine(line, m.delimiter, m.valueColumn))
+	}
+
+	// Excluded keys have no rows left in their own column; keep them as empty
+	// entries so they stay visible and can be un-excluded
+	for value, col := range m.excludedFacetsColumn {
+		if m.filteredData[col] == nil {
+			m.filteredData[col] = make(map[string][]float64)
+		}
+		if _, ok := m.filteredData[col][value]; !ok {
+			m.filteredData[col][value] = []float64{}
+		}
 	}
 }
 
@@ -311,7 +1843,7 @@ func (m *model) navigateGrid(dx, dy int) {
 			for facetCol := range dataSource {
 				// Get sorted keys to initialize with the first displayed facet
 				facetData := dataSource[facetCol]
-				keys := getSortedFacetKeys(facetData)
+				keys := m.visibleFacetKeys(facetCol, facetData)
 				if len(keys) > 0 {
 					m.activeFacet = keys[0]
 					break
@@ -327,7 +1859,7 @@ func (m *model) navigateGrid(dx, dy int) {
 		allKeys := []string{}
 		for facetCol := range dataSource {
 			facetData := dataSource[facetCol]
-			keys := getSortedFacetKeys(facetData)
+			keys := m.visibleFacetKeys(facetCol, facetData)
 			allKeys = append(allKeys, keys...)
 		}
 
@@ -385,7 +1917,7 @@ func (m *model) navigateGrid(dx, dy int) {
 			return
 		}
 
-		keys := getSortedFacetKeys(facetData)
+		keys := m.visibleFacetKeys(m.facet, facetData)
 		if len(keys) == 0 {
 			return
 		}
@@ -409,7 +1941,7 @@ func (m *model) navigateGrid(dx, dy int) {
 		// Calculate grid dimensions
 		columns := m.gridColumns
 		if columns < 1 {
-			columns = max(1, m.winWidth/60) // Use a reasonable estimate if not set
+			columns = max(1, m.renderWidth()/60) // Use a reasonable estimate if not set
 		}
 
 		// Calculate current row and column
@@ -437,6 +1969,78 @@ func (m *model) navigateGrid(dx, dy int) {
 	}
 }
 
+// scrollPage moves the scroll offset by one screen of content in direction
+// dir (-1 up, 1 down), clamped to the scrollable range.
+func (m *model) scrollPage(dir int) {
+	page := m.availableContentHeight(m.renderStatic())
+	m.scrollOffset += dir * page
+	if maxScroll := m.maxScrollOffset(); m.scrollOffset > maxScroll {
+		m.scrollOffset = maxScroll
+	}
+	if m.scrollOffset < 0 {
+		m.scrollOffset = 0
+	}
+}
+
+// navigationKeys returns the facet keys in display order for the current view.
+func (m *model) navigationKeys() []string {
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+
+	if m.facet > 0 {
+		return m.visibleFacetKeys(m.facet, dataSource[m.facet])
+	}
+
+	// In the all-facets view keys are listed column by column
+	facets := dataSource.Columns()
+
+	var keys []string
+	for _, facet := range facets {
+		keys = append(keys, m.visibleFacetKeys(facet, dataSource[facet])...)
+	}
+	return keys
+}
+
+// jumpToFacet selects the first (or, with last set, the final) facet key and
+// scrolls so that it is on screen.
+func (m *model) jumpToFacet(last bool) {
+	keys := m.navigationKeys()
+	if len(keys) == 0 {
+		return
+	}
+
+	index := 0
+	if last {
+		index = len(keys) - 1
+	}
+	m.selectFacetKey(keys, index)
+
+	if last {
+		m.scrollOffset = m.maxScrollOffset()
+		m.ensureActiveFacetVisible()
+	} else {
+		m.scrollOffset = 0
+	}
+}
+
+// selectFacetKey makes keys[index] the active facet key, where keys are the
+// navigationKeys.
+func (m *model) selectFacetKey(keys []string, index int) {
+	m.activeFacet = keys[index]
+
+	if m.facet > 0 {
+		columns := m.gridColumns
+		if columns < 1 {
+			columns = max(1, m.renderWidth()/60) // Use a reasonable estimate if not set
+		}
+		m.activeFacetPos = [2]int{index / columns, index % columns}
+	} else {
+		m.activeFacetPos = [2]int{index, 0}
+	}
+}
+
 // abs returns the absolute value of an integer
 func abs(n int) int {
 	if n < 0 {
@@ -445,6 +2049,67 @@ func abs(n int) int {
 	return n
 }
 
+// hasFacet reports whether facet is a column of the active data source (or
+// the -distinct column), which can be shown.
+func (m model) hasFacet(facet int) bool {
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+	if _, ok := dataSource[facet]; ok {
+		return true
+	}
+	return m.distinct != nil && facet == m.distinctColumn
+}
+
+// maxFacet returns the highest facet column in the active data source.
+func (m model) maxFacet() int {
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+
+	maxFacet := 0
+	for k := range dataSource {
+		if k > maxFacet {
+			maxFacet = k
+		}
+	}
+	if m.distinct != nil {
+		maxFacet = max(maxFacet, m.distinctColumn)
+	}
+	return maxFacet
+}
+
+// facetPlace is where a facet column was left: its scroll offset and the
+// key that was selected.
+type facetPlace struct {
+	scroll int
+	active string
+}
+
+// switchFacet shows another facet column, saving the current column's scroll
+// offset and selected key and restoring the new one's.
+func (m *model) switchFacet(facet int) {
+	if m.facetPlaces == nil {
+		m.facetPlaces = make(map[int]facetPlace)
+	}
+	m.facetPlaces[m.facet] = facetPlace{scroll: m.scrollOffset, active: m.activeFacet}
+	m.facet = facet
+	place := m.facetPlaces[facet]
+	m.scrollOffset = place.scroll
+	m.resetActiveFacet()
+
+	// The key may have been filtered out since
+	keys := m.navigationKeys()
+	for i, key := range keys {
+		if key == place.active {
+			m.selectFacetKey(keys, i)
+			break
+		}
+	}
+}
+
 // resetActiveFacet initializes the active facet state when switching views
 func (m *model) resetActiveFacet() {
 	dataSource := m.facetsData
@@ -461,7 +2126,7 @@ func (m *model) resetActiveFacet() {
 		// Initialize with the first key from the sorted facets
 		for facetCol := range dataSource {
 			facetData := dataSource[facetCol]
-			keys := getSortedFacetKeys(facetData)
+			keys := m.visibleFacetKeys(facetCol, facetData)
 			if len(keys) > 0 {
 				m.activeFacet = keys[0]
 				break
@@ -473,7 +2138,7 @@ func (m *model) resetActiveFacet() {
 
 		// Initialize activeFacet to the first item in the current facet if it's empty
 		if facetData, ok := dataSource[m.facet]; ok {
-			keys := getSortedFacetKeys(facetData)
+			keys := m.visibleFacetKeys(m.facet, facetData)
 			if len(keys) > 0 {
 				m.activeFacet = keys[0]
 			}
@@ -488,34 +2153,174 @@ func (m *model) updatePositionFromActiveFacet() {
 	}
 }
 
-// getSortedFacetKeys returns the keys from a facet map sorted by mean value
-func getSortedFacetKeys(facetData map[string][]float64) []string {
-	// Build a slice of keys and sort them by descending mean
-	keys := make([]string, 0, len(facetData))
-	for k := range facetData {
-		keys = append(keys, k)
+// sortMode selects how facet keys are ordered.
+type sortMode int
+
+const (
+	sortByMean    sortMode = iota // descending mean
+	sortByCount                   // descending sample count
+	sortByName                    // ascending key name
+	sortByStdev                   // descending standard deviation
+	sortByNumeric                 // ascending numeric key value, then other keys by name
+	sortModeCount
+
+	// sortAuto, the default, is sortByNumeric for a column whose keys are
+	// all numbers (status codes, say) and sortByMean for any other. s cycles
+	// on from it through the modes above.
+	sortAuto = sortModeCount
+)
+
+// String returns the display name of the sort mode.
+func (s sortMode) String() string {
+	switch s {
+	case sortByCount:
+		return "count"
+	case sortByName:
+		return "name"
+	case sortByStdev:
+		return "stdev"
+	case sortByNumeric:
+		return "numeric"
+	case sortAuto:
+		return "auto"
 	}
-	sort.Slice(keys, func(i, j int) bool {
-		meanI := computeMean(facetData[keys[i]])
-		meanJ := computeMean(facetData[keys[j]])
-		if meanI != meanJ {
-			return meanI > meanJ
-		}
-		return keys[i] < keys[j] // secondary sort by key name for stability
-	})
-	return keys
+	return "mean"
 }
 
-// ensureActiveFacetVisible ensures the active facet is visible by adjusting scroll
-func (m *model) ensureActiveFacetVisible() {
-	if m.activeFacet == "" {
-		return
+// parseSortMode converts a -sort flag value into a sortMode.
+func parseSortMode(s string) (sortMode, error) {
+	for mode := sortMode(0); mode <= sortAuto; mode++ {
+		if mode.String() == s {
+			return mode, nil
+		}
 	}
+	return sortAuto, fmt.Errorf("unknown sort order %q (want auto, mean, count, name, stdev, or numeric)", s)
+}
 
-	// If position isn't known yet, skip
-	if _, exists := m.facetPositions[m.activeFacet]; !exists {
-		return
-	}
+// numericKey parses a facet key as a number, reporting whether it is one.
+func numericKey(key string) (float64, bool) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(key), 64)
+	return v, err == nil && !math.IsNaN(v)
+}
+
+// next returns the sort mode that follows s, wrapping around. sortAuto is
+// left for the mode after mean, so that mean itself comes round last.
+func (s sortMode) next() sortMode {
+	if s == sortAuto {
+		return sortByCount
+	}
+	return (s + 1) % sortModeCount
+}
+
+// getSortedFacetKeys returns the keys from a facet map ordered by mode,
+// reversed if reverse is set. Ties are broken by key name for stability.
+// Sorting with sortAuto a column whose keys are all numbers orders them by
+// value, and any other by mean. Keys with moving statistics in ewma are
+// sorted by those, as they are displayed, when sorting by mean or stdev.
+func getSortedFacetKeys(facetData map[string][]float64, ewma map[string]*ewmaStat, mode sortMode, reverse bool) []string {
+	keys := make([]string, 0, len(facetData))
+	allNumeric := len(facetData) > 0
+	for k := range facetData {
+		keys = append(keys, k)
+		if _, ok := numericKey(k); !ok {
+			allNumeric = false
+		}
+	}
+	if mode == sortAuto {
+		mode = sortByMean
+		if allNumeric {
+			mode = sortByNumeric
+		}
+	}
+
+	// Compute each key's sort metric once rather than in the comparator
+	metric := make(map[string]float64, len(keys))
+	for _, k := range keys {
+		switch s := ewma[k]; {
+		case mode == sortByMean && s != nil:
+			metric[k] = s.mean
+		case mode == sortByMean:
+			metric[k] = histogram.Mean(facetData[k])
+		case mode == sortByCount:
+			metric[k] = float64(len(facetData[k]))
+		case mode == sortByStdev && s != nil:
+			metric[k] = math.Sqrt(s.variance)
+		case mode == sortByStdev:
+			metric[k] = computeStdev(facetData[k])
+		case mode == sortByNumeric:
+			// Negated so that the descending metric order is ascending by
+			// value; non-numeric keys go last
+			metric[k] = math.Inf(-1)
+			if v, ok := numericKey(k); ok {
+				metric[k] = -v
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		// Metrics sort descending; names (and ties) sort ascending
+		less := keys[i] < keys[j]
+		if mi, mj := metric[keys[i]], metric[keys[j]]; mi != mj {
+			less = mi > mj
+		}
+		if reverse {
+			return !less
+		}
+		return less
+	})
+	return keys
+}
+
+// resort discards the fixed key orders of -stable-order, so keys are sorted
+// again by the current sort mode.
+func (m *model) resort() {
+	if m.stableOrder == nil {
+		return
+	}
+	m.stableOrder.reset()
+	if m.sortedKeys != nil {
+		m.sortedKeys.entries = nil
+	}
+}
+
+// sortedFacetKeys returns getSortedFacetKeys for a facet column, reusing the
+// previous result if the data hasn't changed since it was computed.
+func (m model) sortedFacetKeys(facet int, facetData map[string][]float64) []string {
+	c := m.sortedKeys
+	if c == nil {
+		keys := getSortedFacetKeys(facetData, m.ewmaColumn(facet), m.sortMode, m.sortReverse)
+		if m.stableOrder != nil {
+			keys = m.stableOrder.apply(keyOrderRef{facet: facet, filtered: m.isFiltered}, keys)
+		}
+		return keys
+	}
+	if c.entries == nil || c.version != m.dataVersion {
+		c.entries = make(map[sortedKeyCacheKey][]string)
+		c.version = m.dataVersion
+	}
+
+	key := sortedKeyCacheKey{facet: facet, filtered: m.isFiltered, mode: m.sortMode, reverse: m.sortReverse}
+	keys, ok := c.entries[key]
+	if !ok {
+		keys = getSortedFacetKeys(facetData, m.ewmaColumn(facet), m.sortMode, m.sortReverse)
+		if m.stableOrder != nil {
+			keys = m.stableOrder.apply(keyOrderRef{facet: facet, filtered: m.isFiltered}, keys)
+		}
+		c.entries[key] = keys
+	}
+	return keys
+}
+
+// ensureActiveFacetVisible ensures the active facet is visible by adjusting scroll
+func (m *model) ensureActiveFacetVisible() {
+	if m.activeFacet == "" {
+		return
+	}
+
+	// If position isn't known yet, skip
+	if _, exists := m.facetPositions[m.activeFacet]; !exists {
+		return
+	}
 
 	// Determine row position
 	row := m.activeFacetPos[0]
@@ -525,7 +2330,7 @@ func (m *model) ensureActiveFacetVisible() {
 
 	// Calculate content area height
 	staticHeight := 10 // Estimate for header and instructions
-	availableHeight := m.winHeight - staticHeight
+	availableHeight := max(1, m.winHeight-staticHeight)
 
 	// Calculate row boundaries
 	startRow := m.scrollOffset / rowHeight
@@ -543,822 +2348,5400 @@ func (m *model) ensureActiveFacetVisible() {
 
 // processLine handles a single line of input, storing it for reprocessing if needed
 func (m *model) processLine(line string) {
+	m.processParsedLine(parseLine(line, m.delimiter, m.valueColumn))
+}
+
+// processParsedLine is processLine for a line that has already been parsed.
+func (m *model) processParsedLine(p parsedLine) {
 	// Store the line for potential reprocessing when pins change
-	m.storedLines = append(m.storedLines, line)
+	m.storedLines = append(m.storedLines, p.raw)
+	m.lineTimes = append(m.lineTimes, time.Now())
 
-	// Process the line normally for the main data structure
-	m.processLineWithFilter(line, false)
+	if m.paused {
+		// While paused, lines are only buffered; they are replayed on resume
+		m.pendingLines++
+	} else {
+		// Process the line normally for the main data structure
+		m.processParsedWithFilter(p, false)
 
-	// If we have active filters, also process for filtered data
-	if m.isFiltered {
-		m.processLineWithFilter(line, true)
+		// If we have active filters, also process for filtered data
+		if m.isFiltered {
+			m.processFilteredLine(p)
+		}
 	}
-}
 
-// processLineWithFilter processes a line with optional filtering based on pins
-func (m *model) processLineWithFilter(line string, applyFilter bool) {
-	line = strings.TrimSpace(line)
-	if line == "" {
-		return
+	// Drop the oldest line once the buffer is full
+	if m.maxLines > 0 && len(m.storedLines) > m.maxLines {
+		m.evictOldest(len(m.storedLines) - m.maxLines)
 	}
-	parts := strings.Split(line, "\t")
-	if len(parts) < 1 {
-		return
+}
+
+// processFilteredLine applies a line to the filtered data, remembering it in
+// filteredLines if it passes the current pins.
+func (m *model) processFilteredLine(p parsedLine) {
+	if m.processParsedWithFilter(p, true) {
+		m.filteredLines = append(m.filteredLines, p.raw)
 	}
+}
 
-	// Try to parse as float64
-	value, err := strconv.ParseFloat(parts[0], 64)
+// clearData discards all accumulated data and restarts the rate clock,
+// keeping the current view, pins, and display settings.
+func (m *model) clearData() {
+	m.facetsData = make(histogram.Histogram)
+	m.filteredData = make(histogram.Histogram)
+	m.filteredLines = nil
+	m.ewma = nil
+	m.filteredEwma = nil
+	m.distinct = nil
+	m.filteredDistinct = nil
+	m.dataVersion++
+	m.stringValues = make(map[string]int)
+	m.missingCount = 0
+	m.scaleSkipped = 0
+	m.crossKeys = nil
+	m.crossOther = nil
+	m.storedLines = make([]string, 0)
+	m.lineTimes = nil
+	m.keyArrivals = make(map[int]map[string][]time.Time)
+	m.pendingLines = 0
+	m.totalLogCount = 0
+	m.startTime = time.Now()
+	m.scrollOffset = 0
+	m.facetPlaces = nil
+}
 
-	// For filtered data, check if this line should be included based on pins
-	if applyFilter && len(m.pinnedFacets) > 0 {
-		// Check if all pinned facets match
-		for pinnedValue, isActive := range m.pinnedFacets {
-			if !isActive {
-				continue // Skip non-active pins
-			}
-
-			pinnedCol := m.pinnedFacetsColumn[pinnedValue]
-			// pinnedCol is 1-indexed, parts array is 0-indexed
-			if pinnedCol < len(parts) {
-				facetValue := parts[pinnedCol]
-				if facetValue != pinnedValue {
-					// This line doesn't match a pin, skip it
-					return
-				}
-			}
+// matchesPins reports whether a split input line satisfies every active pin
+// and matches none of the excluded facets.
+func (m *model) matchesPins(parts []string) bool {
+	for excludedValue := range m.excludedFacets {
+		excludedCol := m.excludedFacetsColumn[excludedValue]
+		if excludedCol < len(parts) && parts[excludedCol] == excludedValue {
+			return false
 		}
 	}
 
-	// Handle non-float values (always count strings)
-	if err != nil {
-		if !applyFilter {
-			// Only update global counters when not in filter mode
-			m.stringValues[parts[0]]++
-			m.totalLogCount++
+	for pinnedValue, isActive := range m.pinnedFacets {
+		if !isActive {
+			continue // Skip non-active pins
 		}
+
+		pinnedCol := m.pinnedFacetsColumn[pinnedValue]
+		// pinnedCol is 1-indexed, parts array is 0-indexed
+		if pinnedCol < len(parts) && parts[pinnedCol] != pinnedValue {
+			// This line doesn't match a pin
+			return false
+		}
+	}
+	return true
+}
+
+// evictExpired drops lines that arrived before the sliding window, removing
+// their contribution from the accumulated data.
+func (m *model) evictExpired(now time.Time) {
+	if m.window <= 0 {
 		return
 	}
 
-	// Only increment log count once per line (not for filtered processing)
-	if !applyFilter {
-		m.totalLogCount++
+	cutoff := now.Add(-m.window)
+	expired := 0
+	for expired < len(m.lineTimes) && m.lineTimes[expired].Before(cutoff) {
+		expired++
 	}
+	m.evictOldest(expired)
+}
 
-	// Determine which data structure to update
-	targetData := m.facetsData
-	if applyFilter {
-		targetData = m.filteredData
+// evictOldest drops the count oldest stored lines, removing their
+// contribution from the accumulated data.
+func (m *model) evictOldest(count int) {
+	if count <= 0 {
+		return
 	}
 
-	// For each subsequent column, update the appropriate data structure
-	for i, facet := range parts[1:] {
-		index := i + 1 // facets are 1-indexed
-		if targetData[index] == nil {
-			targetData[index] = make(map[string][]float64)
+	processed := len(m.processedLines())
+	for i := 0; i < count; i++ {
+		if i < processed {
+			m.evictLine(m.storedLines[i])
+		} else {
+			// Still buffered while paused, so it was never counted
+			m.pendingLines--
 		}
-		targetData[index][facet] = append(targetData[index][facet], value)
 	}
+	m.storedLines = m.storedLines[count:]
+	m.lineTimes = m.lineTimes[count:]
 }
 
-// -------------------------
-// Helper Functions
-// -------------------------
-
-// computeMean returns the mean of a slice of float64.
-func computeMean(values []float64) float64 {
-	if len(values) == 0 {
-		return 0.0
-	}
-	sum := 0.0
-	for _, v := range values {
-		sum += v
+// evictLine removes the contribution of the oldest processed line. Values are
+// appended in arrival order, so each of the line's values is the first
+// element of its key's slice.
+func (m *model) evictLine(line string) {
+	p := parseLine(line, m.delimiter, m.valueColumn)
+	if p.parts == nil {
+		return
 	}
-	return sum / float64(len(values))
-}
+	parts, skip := m.facetParts(p.parts)
+	m.countCross(parts, -1)
+	m.totalLogCount--
 
-// globalRange computes the overall min and max across all facets.
-func (m model) globalRange() (gmin, gmax float64, ok bool) {
-	dataSource := m.facetsData
-	if m.isFiltered {
-		dataSource = m.filteredData
+	if m.naTokens[parts[0]] {
+		m.missingCount--
+		return
 	}
 
-	allValues := []float64{}
-	for _, facetMap := range dataSource {
-		for _, values := range facetMap {
-			allValues = append(allValues, values...)
+	value, err := p.value, p.err
+	if err != nil {
+		m.stringValues[parts[0]]--
+		if m.stringValues[parts[0]] <= 0 {
+			delete(m.stringValues, parts[0])
 		}
+		return
 	}
-	if len(allValues) == 0 {
-		return 0, 0, false
+	if _, ok := m.valueScale.apply(value); !ok {
+		m.scaleSkipped--
+		return
+	}
+
+	dropOldestValues(m.facetsData, m.ewma, parts, skip)
+	m.dataVersion++
+	if m.isFiltered && m.matchesPins(parts) {
+		dropOldestValues(m.filteredData, m.filteredEwma, parts, skip)
+		if len(m.filteredLines) > 0 {
+			m.filteredLines = m.filteredLines[1:]
+		}
 	}
-	gmin = allValues[0]
-	gmax = allValues[0]
-	for _, v := range allValues {
-		if v < gmin {
-			gmin = v
+}
+
+// dropOldestValues removes the first value from each facet key named in parts,
+// except in column skip, deleting keys that become empty along with their
+// moving statistics in ewma, so a key that comes back starts afresh.
+func dropOldestValues(data histogram.Histogram, ewma map[int]map[string]*ewmaStat, parts []string, skip int) {
+	for i, facet := range parts[1:] {
+		if i+1 == skip {
+			continue
 		}
-		if v > gmax {
-			gmax = v
+		facetMap := data[i+1] // facets are 1-indexed
+		if values := facetMap[facet]; len(values) > 1 {
+			facetMap[facet] = values[1:]
+		} else {
+			delete(facetMap, facet)
+			delete(ewma[i+1], facet)
 		}
 	}
-	return gmin, gmax, true
 }
 
-// renderStringHistogram creates a horizontal bar chart for string values.
-func (m model) renderStringHistogram() string {
-	if len(m.stringValues) == 0 {
-		return "No string values found."
+// recordKeyArrivals notes the arrival of a value for each facet key in facets,
+// except in column skip.
+func (m *model) recordKeyArrivals(facets []string, skip int, now time.Time) {
+	for i, facet := range facets {
+		index := i + 1 // facets are 1-indexed
+		if index == m.distinctColumn || index == skip || m.ignoredColumns[index] {
+			continue
+		}
+		if m.keyArrivals[index] == nil {
+			m.keyArrivals[index] = make(map[string][]time.Time)
+		}
+		m.keyArrivals[index][facet] = append(m.keyArrivals[index][facet], now)
 	}
+}
 
-	// Sort strings by count (descending)
-	type stringCount struct {
-		value string
-		count int
+// pruneKeyArrivals drops arrival times older than keyRateWindow.
+func (m *model) pruneKeyArrivals(now time.Time) {
+	cutoff := now.Add(-keyRateWindow)
+	for _, keys := range m.keyArrivals {
+		for key, times := range keys {
+			expired := 0
+			for expired < len(times) && times[expired].Before(cutoff) {
+				expired++
+			}
+			if expired == len(times) {
+				delete(keys, key)
+			} else if expired > 0 {
+				keys[key] = times[expired:]
+			}
+		}
 	}
-	counts := make([]stringCount, 0, len(m.stringValues))
-	for value, count := range m.stringValues {
-		counts = append(counts, stringCount{value, count})
+}
+
+// keyRate returns the recent events/sec for a facet key, measured over
+// keyRateWindow (or the time since start, if shorter).
+func (m model) keyRate(facet int, key string) float64 {
+	span := math.Min(time.Since(m.startTime).Seconds(), keyRateWindow.Seconds())
+	if span <= 0 {
+		return 0
 	}
-	sort.Slice(counts, func(i, j int) bool {
-		if counts[i].count != counts[j].count {
-			return counts[i].count > counts[j].count
+
+	cutoff := time.Now().Add(-keyRateWindow)
+	recent := 0
+	for _, t := range m.keyArrivals[facet][key] {
+		if !t.Before(cutoff) {
+			recent++
 		}
-		return counts[i].value < counts[j].value // secondary sort by value for stability
-	})
+	}
+	return float64(recent) / span
+}
 
-	// Find the maximum count for scaling
-	maxCount := counts[0].count
+// processedLines returns the stored lines that have been applied to the data,
+// excluding any still buffered while paused.
+func (m *model) processedLines() []string {
+	return m.storedLines[:len(m.storedLines)-m.pendingLines]
+}
 
-	// Create the histogram
-	var builder strings.Builder
-	barWidth := m.winWidth / 2
+// togglePause pauses ingestion, or resumes it by replaying the lines that
+// arrived while paused.
+func (m *model) togglePause() {
+	if !m.paused {
+		m.paused = true
+		return
+	}
 
-	for _, item := range counts {
-		// Scale the bar length
-		barLength := int(float64(item.count) / float64(maxCount) * float64(barWidth))
-		if barLength < 3 {
-			barLength = 3
+	pending := m.storedLines[len(m.storedLines)-m.pendingLines:]
+	m.paused = false
+	m.pendingLines = 0
+	for _, line := range pending {
+		p := parseLine(line, m.delimiter, m.valueColumn)
+		m.processParsedWithFilter(p, false)
+		if m.isFiltered {
+			m.processFilteredLine(p)
 		}
-
-		bar := strings.Repeat("█", barLength)
-		line := fmt.Sprintf("%-20s %5d %s\n", item.value, item.count, bar)
-		builder.WriteString(line)
 	}
+}
 
-	return builder.String()
+// parsedLine is an input line split into its value and facet columns.
+// Parsing doesn't touch the model, so it can happen off the UI goroutine.
+type parsedLine struct {
+	raw   string
+	parts []string // nil for a blank line; the value comes first
+	value float64
+	err   error // set when the value isn't a number
 }
 
-// createVerticalHistogram builds a vertical bar histogram as a multiline string.
-// It divides the global range [gmin, gmax] into binCount bins and scales the height to barHeight.
-func createVerticalHistogram(values []float64, gmin, gmax float64, binCount, barHeight int) string {
-	if len(values) == 0 {
-		return "No data"
+// parseLine splits a line into columns on delimiter and parses the value in
+// column valueColumn (1-indexed), which is moved to the front of the parts
+// so the facet columns follow it in input order. A line too short to have
+// the value column gets an empty value. NaN and infinities, which have no
+// place on an axis, are rejected like any other non-number.
+func parseLine(line, delimiter string, valueColumn int) parsedLine {
+	p := parsedLine{raw: line}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return p
 	}
-	if gmin == gmax {
-		bar := ""
-		for i := 0; i < barHeight; i++ {
-			bar += "█ "
+	p.parts = strings.Split(line, delimiter)
+	if valueColumn > 1 {
+		parts := make([]string, 1, len(p.parts)+1)
+		if valueColumn <= len(p.parts) {
+			parts[0] = p.parts[valueColumn-1]
+			parts = append(parts, p.parts[:valueColumn-1]...)
+			parts = append(parts, p.parts[valueColumn:]...)
+		} else {
+			parts = append(parts, p.parts...)
 		}
-		return bar + fmt.Sprintf("\n%.2f", gmin)
+		p.parts = parts
 	}
-	binSize := (gmax - gmin) / float64(binCount)
-	bins := make([]int, binCount)
-	for _, v := range values {
-		idx := int((v - gmin) / binSize)
-		if idx >= binCount {
-			idx = binCount - 1
-		}
-		bins[idx]++
+	p.value, p.err = strconv.ParseFloat(p.parts[0], 64)
+	if p.err == nil && (math.IsNaN(p.value) || math.IsInf(p.value, 0)) {
+		p.err = &strconv.NumError{Func: "ParseFloat", Num: p.parts[0], Err: strconv.ErrSyntax}
 	}
-	maxCount := 0
-	for _, count := range bins {
-		if count > maxCount {
-			maxCount = count
+	return p
+}
+
+// nullKey is the facet key that -na sentinels in facet columns become.
+const nullKey = "«null»"
+
+// nullFacets returns parts with -na sentinels in the facet columns replaced
+// by nullKey, copying parts only if there are any.
+func (m *model) nullFacets(parts []string) []string {
+	copied := false
+	for i := 1; i < len(parts); i++ {
+		if !m.naTokens[parts[i]] {
+			continue
 		}
-	}
-	normalized := make([]int, binCount)
-	for i, count := range bins {
-		if count > 0 {
-			// Ensure at least a height of 1 for any non-zero count
-			normalized[i] = max(1, int((float64(count)/float64(maxCount))*float64(barHeight)))
-		} else {
-			normalized[i] = 0
+		if !copied {
+			parts = append([]string(nil), parts...)
+			copied = true
 		}
+		parts[i] = nullKey
 	}
-	var rows []string
-	for row := barHeight; row > 0; row-- {
-		var rowStr string
-		for _, h := range normalized {
-			if h >= row {
-				rowStr += "█ "
-			} else {
-				rowStr += "  "
-			}
+	return parts
+}
+
+// facetParts returns the facet keys of a split line: -na sentinels become
+// nullKey, with -time-col the timestamp becomes its time bucket, and with
+// -cross the combined key is added. skip is the time column if its timestamp
+// can't be parsed, so the line gets no key there, and 0 otherwise.
+func (m *model) facetParts(parts []string) ([]string, int) {
+	parts = m.nullFacets(parts)
+	skip := 0
+	if column := m.timeColumn; column > 0 && column < len(parts) && parts[column] != nullKey {
+		if bucket, ok := m.timeBucketKey(parts[column]); ok {
+			parts = append([]string(nil), parts...)
+			parts[column] = bucket
+		} else {
+			skip = column
 		}
-		rows = append(rows, rowStr)
 	}
-	// Build bottom label row showing the midpoints.
-	var labelParts []string
-	for i := 0; i < binCount; i++ {
-		midpoint := gmin + (float64(i)+0.5)*binSize
-		labelParts = append(labelParts, fmt.Sprintf("%4.1f", midpoint))
+	if m.cross[0] > 0 && len(parts) > 0 {
+		parts = m.crossParts(parts)
 	}
-	labelRow := strings.Join(labelParts, " ")
-	return strings.Join(rows, "\n") + "\n" + labelRow
+	return parts, skip
 }
 
-// -------------------------
-// Rendering Functions
-// -------------------------
+// crossOtherKey is the -cross key of the combinations past -cross-max.
+const crossOtherKey = "«other»"
+
+// crossParts returns parts with the -cross key of its two columns added as
+// column crossColumn. Columns at or past crossColumn, in a line longer than
+// the first, are dropped; a shorter line is padded with nullKey up to it. A
+// line with only a value has no facets to combine and is left alone.
+func (m *model) crossParts(parts []string) []string {
+	a, b := m.cross[0], m.cross[1]
+	if m.crossColumn == 0 {
+		m.crossColumn = max(len(parts), max(a, b)+1)
+	}
+	if len(parts) < 2 {
+		return parts
+	}
 
-// renderHeader creates a header string showing log rate, total count, and pin status.
-func (m model) renderHeader() string {
-	elapsed := time.Since(m.startTime).Seconds()
-	rate := 0.0
-	if elapsed > 0 {
-		rate = float64(m.totalLogCount) / elapsed
+	crossed := make([]string, m.crossColumn+1)
+	n := copy(crossed, parts[:min(len(parts), m.crossColumn)])
+	for i := n; i < m.crossColumn; i++ {
+		crossed[i] = nullKey
 	}
 
-	header := fmt.Sprintf("Log Rate: %.2f logs/sec | Total Logs: %d", rate, m.totalLogCount)
+	// A combination keeps its key while any stored line has it, so
+	// evicting or replaying a line finds its values where they were stored
+	key := crossed[a] + "|" + crossed[b]
+	if m.crossOther[key] > 0 || (m.crossKeys[key] == 0 && len(m.crossKeys) >= m.crossMax) {
+		key = crossOtherKey
+	}
+	crossed[m.crossColumn] = key
+	return crossed
+}
 
-	// Add information about pinned facets if any
-	if m.isFiltered && len(m.pinnedFacets) > 0 {
-		pinnedInfo := " | Pins: "
-		first := true
-		for facet, isPinned := range m.pinnedFacets {
-			if isPinned {
-				if !first {
-					pinnedInfo += ", "
-				}
-				col := m.pinnedFacetsColumn[facet]
-				pinnedInfo += fmt.Sprintf("%d:%s", col, facet)
-				first = false
-			}
-		}
-		header += pinnedInfo
+// countCross adds delta to the count of stored lines with the -cross
+// combination in parts, as returned by facetParts, forgetting combinations
+// whose count drops to zero so they no longer hold a place under crossMax.
+func (m *model) countCross(parts []string, delta int) {
+	if m.cross[0] == 0 || len(parts) <= m.crossColumn {
+		return
+	}
+	counts := &m.crossKeys
+	if parts[m.crossColumn] == crossOtherKey {
+		counts = &m.crossOther
+	}
+	if *counts == nil {
+		*counts = make(map[string]int)
+	}
+	key := parts[m.cross[0]] + "|" + parts[m.cross[1]]
+	(*counts)[key] += delta
+	if (*counts)[key] <= 0 {
+		delete(*counts, key)
 	}
+}
 
-	// Add active facet info for debugging
-	if m.activeFacet != "" {
-		header += fmt.Sprintf(" | Active: %s", m.activeFacet)
+// timeBucketKey parses a -time-col timestamp and returns the start of its
+// -time-bucket as a facet key.
+func (m *model) timeBucketKey(raw string) (string, bool) {
+	var t time.Time
+	if m.timeLayout == "unix" {
+		seconds, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			return "", false
+		}
+		t = time.Unix(0, int64(seconds*1e9)).UTC()
+	} else {
+		var err error
+		if t, err = time.Parse(m.timeLayout, raw); err != nil {
+			return "", false
+		}
 	}
+	// Truncate rounds in UTC, so the bucket is named in UTC too
+	return t.Truncate(m.timeBucket).UTC().Format(timeBucketLayout(m.timeBucket)), true
+}
 
-	return lipgloss.NewStyle().
-		Background(lipgloss.Color("4")).
-		Foreground(lipgloss.Color("15")).
-		Bold(true).
-		Render(header)
+// timeBucketLayout returns the layout time bucket keys are formatted with:
+// as fine as the bucket size, and sorting by name in time order, across
+// years too.
+func timeBucketLayout(bucket time.Duration) string {
+	switch {
+	case bucket < time.Minute:
+		return "2006-01-02 15:04:05"
+	case bucket < 24*time.Hour:
+		return "2006-01-02 15:04"
+	}
+	return "2006-01-02"
 }
 
-// wrapText wraps text to a specified width, preserving words when possible
-func wrapText(text string, width int, maxHeight int) string {
-	if width <= 0 || len(text) <= width {
-		return text
+// processParsedWithFilter processes a line with optional filtering based on pins.
+// It reports whether a numeric value was added to the target data.
+func (m *model) processParsedWithFilter(p parsedLine, applyFilter bool) bool {
+	parts, skip := m.facetParts(p.parts)
+	if len(parts) < 1 {
+		return false
+	}
+	if !applyFilter {
+		m.countCross(parts, 1)
 	}
+	value, err := p.value, p.err
 
-	var wrapped strings.Builder
-	words := strings.Fields(text)
+	// For filtered data, check if this line should be included based on pins
+	if applyFilter && !m.matchesPins(parts) {
+		return false
+	}
 
-	lineCount := 0
-	line := ""
-	for _, word := range words {
-		if len(line)+len(word)+1 <= width {
-			if line != "" {
-				line += " "
-			}
-			line += word
-		} else {
-			if line != "" {
-				wrapped.WriteString(line + "\n")
-				lineCount++
+	// A missing value is tallied on its own rather than as a string value
+	if m.naTokens[parts[0]] {
+		if !applyFilter {
+			m.missingCount++
+			m.totalLogCount++
+		}
+		return false
+	}
 
-				// Check if we've reached maxHeight
+	// Handle non-float values (always count strings)
+	if err != nil {
+		if !applyFilter {
+			// Only update global counters when not in filter mode
+			m.stringValues[parts[0]]++
+			m.totalLogCount++
+		}
+		return false
+	}
+
+	value, ok := m.valueScale.apply(value)
+	if !ok {
+		if !applyFilter {
+			m.scaleSkipped++
+			m.totalLogCount++
+		}
+		return false
+	}
+
+	// Only increment log count once per line (not for filtered processing)
+	if !applyFilter {
+		m.totalLogCount++
+		m.recordKeyArrivals(parts[1:], skip, time.Now())
+	}
+
+	// Determine which data structure to update
+	targetData := m.facetsData
+	if applyFilter {
+		targetData = m.filteredData
+	}
+
+	// For each subsequent column, update the appropriate data structure
+	lowest := 0
+	for i, facet := range parts[1:] {
+		index := i + 1 // facets are 1-indexed
+		if index == skip || m.ignoredColumns[index] {
+			continue
+		}
+		if index == m.distinctColumn {
+			// Only the sketch is kept for this column, not per-key values
+			sketch := &m.distinct
+			if applyFilter {
+				sketch = &m.filteredDistinct
+			}
+			if *sketch == nil {
+				*sketch = newHyperLogLog()
+			}
+			(*sketch).add(facet)
+			continue
+		}
+		targetData.AddTo(index, facet, value)
+		if lowest == 0 {
+			lowest = index
+		}
+		if m.ewmaAlpha > 0 {
+			m.addEwma(applyFilter, index, facet, value)
+		}
+	}
+	m.addedLine(applyFilter, value, lowest, parts, skip)
+	return true
+}
+
+// -------------------------
+// Helper Functions
+// -------------------------
+
+// autoBinCount picks a bin count for an ascending sorted slice using the
+// Freedman-Diaconis rule, falling back to Sturges' rule for small samples or
+// when the IQR is zero.
+func autoBinCount(sorted []float64) int {
+	n := len(sorted)
+	if n == 0 {
+		return 1
+	}
+	sturges := int(math.Ceil(math.Log2(float64(n)))) + 1
+
+	// Freedman-Diaconis is unreliable with only a handful of samples
+	const minFDSamples = 30
+	if n < minFDSamples {
+		return sturges
+	}
+
+	iqr := histogram.Percentile(sorted, 75) - histogram.Percentile(sorted, 25)
+	dataRange := sorted[n-1] - sorted[0]
+	if iqr <= 0 || dataRange <= 0 {
+		return sturges
+	}
+
+	binWidth := 2 * iqr / math.Cbrt(float64(n))
+	return max(1, int(math.Ceil(dataRange/binWidth)))
+}
+
+// binCountFor returns the number of bins to use for values, given the view's
+// default count and the maximum number of bins that fit on screen.
+func (m model) binCountFor(values []float64, defaultCount, maxCount int) int {
+	count := defaultCount
+	if m.autoBins {
+		count = autoBinCount(m.keyStats(values).Sorted)
+	} else if m.binCount > 0 {
+		count = m.binCount
+	}
+	if m.autoBins && count > maxCount {
+		count = maxCount
+	}
+	return max(1, count)
+}
+
+// binningFor divides [gmin, gmax] into count bins for a view that draws
+// values: equal-width (or log-spaced) bins, or with -bins equalfreq, bins
+// with edges at quantiles of values. With -zero, equal-width bins of a range
+// straddling zero are aligned so zero is an edge.
+func (m model) binningFor(values []float64, gmin, gmax float64, count int) histogram.Binning {
+	if m.equalFreq {
+		return histogram.NewQuantileBinning(m.keyStats(values).Sorted, count)
+	}
+	if m.zeroBaseline && !m.logScale && gmin < 0 && gmax > 0 {
+		return histogram.NewZeroAlignedBinning(gmin, gmax, count)
+	}
+	return histogram.NewBinning(gmin, gmax, count, m.logScale)
+}
+
+// allValues returns every value in the active data source. The slice is
+// cached until the data changes, so it must not be modified.
+func (m model) allValues() []float64 {
+	c := m.statsCache
+	if c != nil {
+		c.sync(m.dataVersion)
+		if all := c.all[boolIndex(m.isFiltered)]; all != nil {
+			return all
+		}
+	}
+
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+
+	allValues := []float64{}
+	for _, facetMap := range dataSource {
+		for _, values := range facetMap {
+			allValues = append(allValues, values...)
+		}
+	}
+	if c != nil {
+		c.all[boolIndex(m.isFiltered)] = allValues
+	}
+	return allValues
+}
+
+// computeStdev returns the population standard deviation of a slice of float64.
+func computeStdev(values []float64) float64 {
+	_, stdev := histogram.MeanStdev(values, false)
+	return stdev
+}
+
+// trimmedMean returns the mean of an ascending sorted slice after dropping
+// the lowest and highest p percent of its values. If that would drop
+// everything, it returns the median.
+func trimmedMean(sorted []float64, p float64) float64 {
+	k := int(float64(len(sorted)) * p / 100)
+	if 2*k >= len(sorted) {
+		return histogram.Percentile(sorted, 50)
+	}
+	return histogram.Mean(sorted[k : len(sorted)-k])
+}
+
+// tCritical95 holds the two-sided 95% critical values of Student's t
+// distribution for 1 to 30 degrees of freedom.
+var tCritical95 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// meanConfidence returns the half-width of the 95% confidence interval for
+// the mean of n values with standard deviation stdev, which is corrected to
+// the sample standard deviation unless sample says it already is. Small
+// samples use the t distribution, larger ones the normal 1.96. It is
+// undefined (ok is false) for fewer than two values.
+func meanConfidence(stdev float64, n int, sample bool) (halfWidth float64, ok bool) {
+	if n < 2 {
+		return 0, false
+	}
+	if !sample {
+		stdev *= math.Sqrt(float64(n) / float64(n-1))
+	}
+	critical := 1.96
+	if df := n - 1; df <= len(tCritical95) {
+		critical = tCritical95[df-1]
+	}
+	return critical * stdev / math.Sqrt(float64(n)), true
+}
+
+// formatConfidence formats the confidence interval half-width as "±X", or
+// "±n/a" when it is undefined.
+func (m model) formatConfidence(stdev float64, n int) string {
+	plusMinus := "±"
+	if m.ascii {
+		plusMinus = "+-"
+	}
+	halfWidth, ok := meanConfidence(stdev, n, m.sampleStdev && m.ewmaAlpha == 0)
+	if !ok {
+		return plusMinus + "n/a"
+	}
+	return plusMinus + formatFloat(halfWidth, m.precision, 2)
+}
+
+// geometricMean returns the geometric mean of values, computed as the
+// exponential of the mean log so large products can't overflow. It is
+// undefined (ok is false) if any value isn't positive.
+func geometricMean(values []float64) (mean float64, ok bool) {
+	if len(values) == 0 {
+		return 0, false
+	}
+	sum := 0.0
+	for _, v := range values {
+		if !(v > 0) {
+			return 0, false
+		}
+		sum += math.Log(v)
+	}
+	return math.Exp(sum / float64(len(values))), true
+}
+
+// formatGeomean formats a key's geometric mean, or "n/a" if it is undefined.
+func (m model) formatGeomean(values []float64) string {
+	if mean, ok := geometricMean(values); ok {
+		return formatFloat(mean, m.precision, 2)
+	}
+	return "n/a"
+}
+
+// trimPercent formats the -trim percentage for labels, which always name
+// it so the trimmed mean isn't mistaken for the plain one.
+func (m model) trimPercent() string {
+	return strconv.FormatFloat(m.trim, 'g', -1, 64) + "%"
+}
+
+// keyTrimmedMean returns the trimmed mean of a key's values.
+func (m model) keyTrimmedMean(values []float64) float64 {
+	return trimmedMean(m.keyStats(values).Sorted, m.trim)
+}
+
+// hllPrecision is the number of hash bits that pick a HyperLogLog register.
+const hllPrecision = 14
+
+// hyperLogLog estimates the number of distinct strings added to it in a
+// fixed 2^hllPrecision bytes.
+type hyperLogLog struct {
+	registers []uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{registers: make([]uint8, 1<<hllPrecision)}
+}
+
+// add records s in the sketch.
+func (h *hyperLogLog) add(s string) {
+	f := fnv.New64a()
+	f.Write([]byte(s))
+	// FNV's high bits mix poorly for short inputs; finish with SplitMix64's
+	// finalizer so every bit depends on the whole string
+	x := f.Sum64()
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	x ^= x >> 31
+
+	index := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+// estimate returns the estimated number of distinct strings added.
+func (h *hyperLogLog) estimate() float64 {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// Small cardinalities are estimated better by linear counting
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return e
+}
+
+// relativeError returns the estimate's standard error as a fraction.
+func (h *hyperLogLog) relativeError() float64 {
+	return 1.04 / math.Sqrt(float64(len(h.registers)))
+}
+
+// distinctSummary describes the distinct-count sketch of the current data.
+func (m model) distinctSummary() string {
+	sketch := m.distinct
+	if m.isFiltered {
+		sketch = m.filteredDistinct
+	}
+	if sketch == nil {
+		return fmt.Sprintf("Facet %d: no values yet", m.distinctColumn)
+	}
+	return fmt.Sprintf("Facet %d: ~%.0f distinct values (±%s%%, HyperLogLog)", m.distinctColumn,
+		sketch.estimate(), formatFloat(100*sketch.relativeError(), m.precision, 2))
+}
+
+// ewmaStat is an exponentially weighted moving mean and variance.
+type ewmaStat struct {
+	mean, variance float64
+	started        bool
+}
+
+// add folds x into the statistics with weight alpha.
+func (s *ewmaStat) add(x, alpha float64) {
+	if !s.started {
+		s.mean, s.started = x, true
+		return
+	}
+	diff := x - s.mean
+	incr := alpha * diff
+	s.mean += incr
+	s.variance = (1 - alpha) * (s.variance + diff*incr)
+}
+
+// addEwma updates the moving statistics of a key in facetsData, or in
+// filteredData if filtered is set.
+func (m *model) addEwma(filtered bool, column int, key string, value float64) {
+	stats := &m.ewma
+	if filtered {
+		stats = &m.filteredEwma
+	}
+	if *stats == nil {
+		*stats = make(map[int]map[string]*ewmaStat)
+	}
+	if (*stats)[column] == nil {
+		(*stats)[column] = make(map[string]*ewmaStat)
+	}
+	s := (*stats)[column][key]
+	if s == nil {
+		s = &ewmaStat{}
+		(*stats)[column][key] = s
+	}
+	s.add(value, m.ewmaAlpha)
+}
+
+// ewmaColumn returns the moving statistics of a facet column's keys in the
+// active data source, or nil without -ewma.
+func (m model) ewmaColumn(column int) map[string]*ewmaStat {
+	if m.ewmaAlpha <= 0 {
+		return nil
+	}
+	if m.isFiltered {
+		return m.filteredEwma[column]
+	}
+	return m.ewma[column]
+}
+
+// keyMeanStdev returns the mean and standard deviation displayed for a key:
+// the moving statistics in -ewma mode, otherwise those of all its values.
+func (m model) keyMeanStdev(column int, key string, values []float64) (mean, stdev float64) {
+	if s := m.ewmaColumn(column)[key]; s != nil {
+		return s.mean, math.Sqrt(s.variance)
+	}
+	s := m.keyStats(values)
+	return s.Mean, s.Stdev
+}
+
+// keyStats returns the Stats of values, which are a key's values or
+// allValues, reusing them if they were summarized since the data changed.
+func (m model) keyStats(values []float64) histogram.Stats {
+	c := m.statsCache
+	if c == nil || len(values) == 0 {
+		return histogram.Summarize(values, m.sampleStdev)
+	}
+	c.sync(m.dataVersion)
+	key := statsCacheKey{first: &values[0], n: len(values)}
+	s, ok := c.entries[key]
+	if !ok {
+		s = histogram.Summarize(values, m.sampleStdev)
+		c.entries[key] = s
+	}
+	return s
+}
+
+// ewmaHalfLife returns the number of samples after which a value's weight
+// in the moving statistics has halved.
+func ewmaHalfLife(alpha float64) float64 {
+	return math.Log(0.5) / math.Log(1-alpha)
+}
+
+// keyEntropy returns the Shannon entropy, in bits, of how a facet column's
+// samples are spread over its keys, and that entropy normalized by its
+// maximum, log2 of the number of keys with samples: 1 is perfectly even, 0
+// is a single key. Keys without samples, like excluded ones in the filtered
+// data, don't count.
+func keyEntropy(facetData map[string][]float64) (entropy, normalized float64) {
+	total, keys := 0, 0
+	for _, values := range facetData {
+		total += len(values)
+		if len(values) > 0 {
+			keys++
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	for _, values := range facetData {
+		if len(values) == 0 {
+			continue
+		}
+		p := float64(len(values)) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	if keys > 1 {
+		normalized = entropy / math.Log2(float64(keys))
+	}
+	return entropy, normalized
+}
+
+// globalRange computes the overall min and max across all facets. The result
+// is cached until new data arrives or the filtered data source changes.
+func (m model) globalRange() (gmin, gmax float64, ok bool) {
+	gmin, gmax, _, ok = m.globalStats()
+	return gmin, gmax, ok
+}
+
+// valueTotal returns the sum of every line's value in the active data source.
+func (m model) valueTotal() float64 {
+	_, _, sum, _ := m.globalStats()
+	return sum
+}
+
+// globalStats computes globalRange and valueTotal in one pass over the data.
+// Each line's value is stored once per facet column, so the sum is taken
+// over the lowest column, which holds every stored line.
+func (m model) globalStats() (gmin, gmax, sum float64, ok bool) {
+	e := m.globalEntry()
+	return e.gmin, e.gmax, e.sum, e.ok
+}
+
+// globalExtremes returns the facet keys, by column, of the smallest and
+// largest values in the active data source.
+func (m model) globalExtremes() (minKeys, maxKeys map[int]string) {
+	e := m.globalEntry()
+	return e.minKeys, e.maxKeys
+}
+
+// globalEntry returns the globalStats of the active data source, from the
+// cache if it is current and otherwise by scanning the data.
+func (m model) globalEntry() globalRangeEntry {
+	var cached *globalRangeEntry
+	if m.rangeCache != nil {
+		cached = &m.rangeCache.entries[boolIndex(m.isFiltered)]
+		if cached.valid && cached.version == m.dataVersion {
+			return *cached
+		}
+	}
+
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+	e := globalRangeEntry{valid: true, version: m.dataVersion}
+	for facet := range dataSource {
+		if e.lowest == 0 || facet < e.lowest {
+			e.lowest = facet
+		}
+	}
+	// Each column's key holding its smallest and largest value; the ones
+	// holding the global extremes are kept below
+	type extreme struct {
+		value float64
+		key   string
+	}
+	colMin := make(map[int]extreme)
+	colMax := make(map[int]extreme)
+	for facet, facetMap := range dataSource {
+		for key, values := range facetMap {
+			if facet == e.lowest {
+				for _, v := range values {
+					e.sum += v
+				}
+			}
+			vmin, vmax, found := valueRange(values)
+			if !found {
+				continue
+			}
+			if !e.ok || vmin < e.gmin {
+				e.gmin = vmin
+			}
+			if !e.ok || vmax > e.gmax {
+				e.gmax = vmax
+			}
+			e.ok = true
+			// Ties go to the first key by name, so the choice is stable
+			if c, seen := colMin[facet]; !seen || vmin < c.value || (vmin == c.value && key < c.key) {
+				colMin[facet] = extreme{vmin, key}
+			}
+			if c, seen := colMax[facet]; !seen || vmax > c.value || (vmax == c.value && key < c.key) {
+				colMax[facet] = extreme{vmax, key}
+			}
+		}
+	}
+	e.minKeys = make(map[int]string)
+	e.maxKeys = make(map[int]string)
+	for facet, c := range colMin {
+		if c.value == e.gmin {
+			e.minKeys[facet] = c.key
+		}
+	}
+	for facet, c := range colMax {
+		if c.value == e.gmax {
+			e.maxKeys[facet] = c.key
+		}
+	}
+
+	if cached != nil {
+		*cached = e
+	}
+	return e
+}
+
+// addedLine bumps the data version after a line's value has been added to
+// the filtered or unfiltered data, at lowest and higher facet columns. The
+// line is folded into that source's globalStats entry, and the other entry,
+// whose data didn't change, is carried over to the new version, so neither
+// needs a rescan.
+func (m *model) addedLine(filtered bool, value float64, lowest int, parts []string, skip int) {
+	version := m.dataVersion
+	m.dataVersion++
+	if m.rangeCache == nil {
+		return
+	}
+	for i := range m.rangeCache.entries {
+		e := &m.rangeCache.entries[i]
+		if !e.valid || e.version != version {
+			continue
+		}
+		if i == boolIndex(filtered) && lowest > 0 {
+			// A new extreme is attributed to this line's keys
+			newMin, newMax := !e.ok || value < e.gmin, !e.ok || value > e.gmax
+			if !e.add(value, lowest) {
+				e.valid = false
+				continue
+			}
+			if newMin {
+				e.minKeys = m.storedKeys(parts, skip)
+			}
+			if newMax {
+				e.maxKeys = m.storedKeys(parts, skip)
+			}
+		}
+		e.version = m.dataVersion
+	}
+}
+
+// extremeKeys formats the facet keys an extreme value was recorded under as
+// " (1:sea 2:/api/orders)", by column, or "" if there are none.
+func extremeKeys(keys map[int]string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	columns := make([]int, 0, len(keys))
+	for column := range keys {
+		columns = append(columns, column)
+	}
+	sort.Ints(columns)
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = fmt.Sprintf("%d:%s", column, keys[column])
+	}
+	return " (" + strings.Join(parts, " ") + ")"
+}
+
+// storedKeys returns the facet keys, by column, under which a line's value
+// was stored: every facet column except skip, ignored columns, and the
+// -distinct column.
+func (m *model) storedKeys(parts []string, skip int) map[int]string {
+	keys := make(map[int]string)
+	for i, key := range parts[1:] {
+		index := i + 1 // facets are 1-indexed
+		if index == skip || index == m.distinctColumn || m.ignoredColumns[index] {
+			continue
+		}
+		keys[index] = key
+	}
+	return keys
+}
+
+// boolIndex returns 1 for true and 0 for false.
+func boolIndex(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// parseBinsFlag parses a -bins flag value: empty for the defaults, "auto",
+// "equalfreq" (optionally with a count, as "equalfreq:20"), or a positive
+// bin count.
+func parseBinsFlag(s string) (count int, auto, equalFreq bool, err error) {
+	switch s {
+	case "":
+		return 0, false, false, nil
+	case "auto":
+		return 0, true, false, nil
+	case "equalfreq":
+		return 0, false, true, nil
+	}
+	if strings.HasPrefix(s, "equalfreq:") {
+		s, equalFreq = strings.TrimPrefix(s, "equalfreq:"), true
+	}
+	count, err = strconv.Atoi(s)
+	if err != nil || count < 1 {
+		return 0, false, false, fmt.Errorf("invalid bin count %q (want a positive integer, auto, or equalfreq[:N])", s)
+	}
+	return count, false, equalFreq, nil
+}
+
+// valueScale is a -scale transform of the values: a logarithm, or a
+// multiplication or division by a constant.
+type valueScale struct {
+	spec   string // the flag value, for labels; "" for no transform
+	log    func(float64) float64
+	factor float64
+}
+
+// parseValueScale parses a -scale flag value: "log" or "log10" for base-10
+// logarithms, "ln" for natural ones, "/N" to divide by N, or "N" or "*N" to
+// multiply by N.
+func parseValueScale(s string) (valueScale, error) {
+	switch s {
+	case "":
+		return valueScale{}, nil
+	case "log", "log10":
+		return valueScale{spec: "log10", log: math.Log10}, nil
+	case "ln":
+		return valueScale{spec: "ln", log: math.Log}, nil
+	}
+	number := strings.TrimPrefix(strings.TrimPrefix(s, "*"), "/")
+	factor, err := strconv.ParseFloat(number, 64)
+	if err != nil || factor == 0 || math.IsInf(factor, 0) || math.IsNaN(factor) {
+		return valueScale{}, fmt.Errorf("invalid -scale %q (want log, log10, ln, /N or a nonzero multiplier N)", s)
+	}
+	if strings.HasPrefix(s, "/") {
+		factor = 1 / factor
+	}
+	return valueScale{spec: s, factor: factor}, nil
+}
+
+// apply transforms v, or reports false if it can't be: logarithms skip
+// non-positive values rather than offset them, which would distort the rest,
+// and a factor skips values it overflows.
+func (s valueScale) apply(v float64) (float64, bool) {
+	switch {
+	case s.log != nil:
+		if v <= 0 {
+			return 0, false
+		}
+		return s.log(v), true
+	case s.factor != 0:
+		v *= s.factor
+		return v, !math.IsInf(v, 0)
+	}
+	return v, true
+}
+
+// label describes the transformed values, like "log10(value)" or
+// "value/1e6", or returns "" if there is no transform.
+func (s valueScale) label() string {
+	switch {
+	case s.spec == "":
+		return ""
+	case s.log != nil:
+		return s.spec + "(value)"
+	case strings.HasPrefix(s.spec, "/"):
+		return "value" + s.spec
+	}
+	return "value*" + strings.TrimPrefix(s.spec, "*")
+}
+
+// parseDelimiter parses a -delimiter flag value, where `\t` and "tab" stand
+// for a tab, which is awkward to type on a command line.
+func parseDelimiter(s string) (string, error) {
+	switch s {
+	case "":
+		return "", fmt.Errorf("-delimiter must not be empty")
+	case `\t`, "tab":
+		return "\t", nil
+	}
+	return s, nil
+}
+
+// parseCrossFlag parses a -cross flag value: two distinct facet columns
+// separated by a comma, like "2,3".
+func parseCrossFlag(s string) ([2]int, error) {
+	var cross [2]int
+	if s == "" {
+		return cross, nil
+	}
+	fields := strings.Split(s, ",")
+	if len(fields) == 2 {
+		a, errA := strconv.Atoi(strings.TrimSpace(fields[0]))
+		b, errB := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if errA == nil && errB == nil && a >= 1 && b >= 1 && a != b {
+			return [2]int{a, b}, nil
+		}
+	}
+	return cross, fmt.Errorf("invalid -cross %q (want two different facet columns, like 2,3)", s)
+}
+
+// parseColumnList parses an -ignore-cols flag value: comma-separated facet
+// column numbers (1-indexed; column 0 holds the values).
+func parseColumnList(s string) (map[int]bool, error) {
+	columns := make(map[int]bool)
+	if s == "" {
+		return columns, nil
+	}
+	for _, field := range strings.Split(s, ",") {
+		column, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || column < 1 {
+			return nil, fmt.Errorf("invalid facet column %q in %q (want positive integers separated by commas)", field, s)
+		}
+		columns[column] = true
+	}
+	return columns, nil
+}
+
+// naTokenList formats -na sentinels as an -na value.
+func naTokenList(tokens map[string]bool) string {
+	list := make([]string, 0, len(tokens))
+	for token := range tokens {
+		list = append(list, token)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
+
+// ignoredColumnList formats ignored facet columns as an -ignore-cols value.
+func ignoredColumnList(columns map[int]bool) string {
+	list := make([]int, 0, len(columns))
+	for column := range columns {
+		list = append(list, column)
+	}
+	sort.Ints(list)
+	parts := make([]string, len(list))
+	for i, column := range list {
+		parts[i] = strconv.Itoa(column)
+	}
+	return strings.Join(parts, ",")
+}
+
+// crossList formats -cross columns as a -cross value.
+func crossList(cross [2]int) string {
+	if cross[0] == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d,%d", cross[0], cross[1])
+}
+
+// valueRange computes the min and max of a slice of float64.
+func valueRange(values []float64) (vmin, vmax float64, ok bool) {
+	if len(values) == 0 {
+		return 0, 0, false
+	}
+	vmin = values[0]
+	vmax = values[0]
+	for _, v := range values {
+		if v < vmin {
+			vmin = v
+		}
+		if v > vmax {
+			vmax = v
+		}
+	}
+	return vmin, vmax, true
+}
+
+// stringCount is how many times a string value was seen.
+type stringCount struct {
+	value string
+	count int
+}
+
+// stringCounts returns the string values seen, most common first, and the
+// total of their counts.
+func (m model) stringCounts() ([]stringCount, int) {
+	counts := make([]stringCount, 0, len(m.stringValues))
+	total := 0
+	for value, count := range m.stringValues {
+		counts = append(counts, stringCount{value, count})
+		total += count
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count != counts[j].count {
+			return counts[i].count > counts[j].count
+		}
+		return counts[i].value < counts[j].value // secondary sort by value for stability
+	})
+	return counts, total
+}
+
+// renderStringHistogram creates a horizontal bar chart for string values.
+func (m model) renderStringHistogram() string {
+	if len(m.stringValues) == 0 {
+		return "No string values found."
+	}
+	counts, total := m.stringCounts()
+
+	// Find the maximum count for scaling
+	maxCount := counts[0].count
+
+	// Create the histogram
+	var builder strings.Builder
+	// The bars get half the width, less the 7 columns of each share
+	const shareWidth = len("100.0% ")
+	barWidth := max(1, m.renderWidth()/2-shareWidth)
+
+	// Make it clear why the numeric histograms aren't shown
+	if len(m.facetsData) == 0 {
+		builder.WriteString("No numeric values in the first column yet; counting its strings instead.\n\n")
+	}
+
+	// Keep the most common values, summarizing the rest on one line
+	var rest []stringCount
+	if m.topStrings > 0 && len(counts) > m.topStrings {
+		builder.WriteString(fmt.Sprintf("Top %d of %d values (%d total)\n\n", m.topStrings, len(counts), total))
+		counts, rest = counts[:m.topStrings], counts[m.topStrings:]
+	}
+
+	for _, item := range counts {
+		// Scale the bar length
+		barLength := int(float64(item.count) / float64(maxCount) * float64(barWidth))
+		if barLength < 3 {
+			barLength = 3
+		}
+
+		barGlyph := "█"
+		if m.ascii {
+			barGlyph = "#"
+		}
+		bar := strings.Repeat(barGlyph, barLength)
+		share := float64(item.count) / float64(total) * 100
+		line := fmt.Sprintf("%-20s %5d %5.1f%% %s\n", item.value, item.count, share, bar)
+		builder.WriteString(line)
+	}
+	if len(rest) > 0 {
+		restTotal := 0
+		for _, item := range rest {
+			restTotal += item.count
+		}
+		ellipsis := "…"
+		if m.ascii {
+			ellipsis = "..."
+		}
+		builder.WriteString(fmt.Sprintf("%s and %d more, %d total\n", ellipsis, len(rest), restTotal))
+	}
+
+	return builder.String()
+}
+
+// outlierStyle colors histogram cells holding outliers.
+var outlierStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+
+// negativeStyle colors the bars of bins below zero with -zero.
+var negativeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("75"))
+
+// sparseStyle dims the titles of keys under -min-count.
+var sparseStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+
+// zeroEdge returns the index of the bin edge at zero, or -1 if zero isn't an
+// inner edge of b, as it is for bins from histogram.NewZeroAlignedBinning
+// that straddle zero.
+func zeroEdge(b histogram.Binning) int {
+	if b.Log || !(b.Min < 0 && b.Max > 0) {
+		return -1
+	}
+	// Edges are computed from Min, so allow for rounding
+	tolerance := (b.Max - b.Min) * 1e-9
+	for i := 1; i < b.Count; i++ {
+		if math.Abs(b.Edge(i)) <= tolerance {
+			return i
+		}
+	}
+	return -1
+}
+
+// outlierFences returns Tukey's fences, Q1 - 1.5·IQR and Q3 + 1.5·IQR, of
+// an ascending sorted slice.
+func outlierFences(sorted []float64) (lo, hi float64) {
+	q1, q3 := histogram.Percentile(sorted, 25), histogram.Percentile(sorted, 75)
+	iqr := q3 - q1
+	return q1 - 1.5*iqr, q3 + 1.5*iqr
+}
+
+// outlierBins reports which bins hold values outside the fences of the
+// values stats summarizes, and how many such values there are.
+func outlierBins(stats histogram.Stats, b histogram.Binning) ([]bool, int) {
+	lo, hi := outlierFences(stats.Sorted)
+
+	flagged := make([]bool, b.Count)
+	count := 0
+	for _, v := range stats.Sorted {
+		if v < lo || v > hi {
+			flagged[b.Index(v)] = true
+			count++
+		}
+	}
+	return flagged, count
+}
+
+// barStyle selects the glyphs used to draw vertical histogram bars.
+type barStyle int
+
+const (
+	barSolid  barStyle = iota // full-cell blocks only
+	barSmooth                 // eighth-block glyphs for fractional bar tops
+	barASCII                  // plain '#' characters for limited terminals
+)
+
+// eighthBlocks holds the partial-height glyphs indexed by eighths filled.
+var eighthBlocks = []string{" ", "▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+
+// parseBarStyle converts a -bars flag value into a barStyle.
+func parseBarStyle(s string) (barStyle, error) {
+	switch s {
+	case "solid":
+		return barSolid, nil
+	case "smooth":
+		return barSmooth, nil
+	case "ascii":
+		return barASCII, nil
+	}
+	return barSolid, fmt.Errorf("unknown bar style %q (want solid, smooth, or ascii)", s)
+}
+
+// String returns the -bars flag value for the style.
+func (s barStyle) String() string {
+	switch s {
+	case barSmooth:
+		return "smooth"
+	case barASCII:
+		return "ascii"
+	}
+	return "solid"
+}
+
+// resolution returns the number of height steps a single cell can represent.
+func (s barStyle) resolution() int {
+	if s == barSmooth {
+		return len(eighthBlocks) - 1
+	}
+	return 1
+}
+
+// glyph returns the character for a cell filled to fill steps out of resolution.
+func (s barStyle) glyph(fill int) string {
+	switch {
+	case fill <= 0:
+		return " "
+	case s == barASCII:
+		return "#"
+	case fill >= s.resolution():
+		return "█"
+	}
+	return eighthBlocks[fill]
+}
+
+// histogramOptions controls how createVerticalHistogram draws its bars.
+type histogramOptions struct {
+	barHeight int
+	style     barStyle
+	// density scales bins by the facet's own total instead of raw counts.
+	density bool
+	// yAxis adds count tick labels in a left margin.
+	yAxis bool
+	// marker adds a row above the bars marking the mean and/or median bin.
+	marker markerMode
+	// precision is passed to formatFloat for value labels.
+	precision int
+	// overflow adds a row counting the values outside the binned range.
+	overflow bool
+	// outliers highlights bins holding outliers: in color, or without
+	// color (noColor) with a row of ! under them.
+	outliers bool
+	noColor  bool
+	// zero draws a baseline at zero, with the negative bins set apart, when
+	// the bins straddle it.
+	zero bool
+}
+
+// markerMode selects which central-tendency markers are drawn above a histogram.
+type markerMode int
+
+const (
+	markerNone markerMode = iota
+	markerMean
+	markerMedian
+	markerBoth
+)
+
+// parseMarkerMode converts a -marker flag value into a markerMode.
+func parseMarkerMode(s string) (markerMode, error) {
+	switch s {
+	case "none":
+		return markerNone, nil
+	case "mean":
+		return markerMean, nil
+	case "median":
+		return markerMedian, nil
+	case "both":
+		return markerBoth, nil
+	}
+	return markerNone, fmt.Errorf("unknown marker %q (want none, mean, median, or both)", s)
+}
+
+// String returns the -marker flag value for the mode.
+func (mm markerMode) String() string {
+	switch mm {
+	case markerMean:
+		return "mean"
+	case markerMedian:
+		return "median"
+	case markerBoth:
+		return "both"
+	}
+	return "none"
+}
+
+// histogramOptions returns the vertical histogram settings for the current model.
+func (m model) histogramOptions(barHeight int) histogramOptions {
+	style := m.bars
+	if m.ascii {
+		style = barASCII
+	}
+	return histogramOptions{
+		barHeight: barHeight,
+		style:     style,
+		density:   m.density,
+		yAxis:     m.yAxis,
+		marker:    m.marker,
+		precision: m.precision,
+		overflow:  m.showOverflow,
+		outliers:  m.outliers,
+		noColor:   m.noColor,
+		zero:      m.zeroBaseline,
+	}
+}
+
+// formatFloat formats v with precision decimal places, or with def places if
+// precision is negative.
+func formatFloat(v float64, precision, def int) string {
+	if precision < 0 {
+		precision = def
+	}
+	return strconv.FormatFloat(v, 'f', precision, 64)
+}
+
+// binWeights returns the bin counts as floats, normalized to relative
+// frequency (summing to 1) when density is set.
+func binWeights(b histogram.Binning, counts []int, density bool) []float64 {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	weights := make([]float64, len(counts))
+	for i, c := range counts {
+		weights[i] = float64(c)
+		if density && total > 0 {
+			weights[i] /= float64(total)
+		}
+		// Bins of differing widths are compared by count per unit width,
+		// or a wide bin would look tall just for covering more of the range
+		if b.Variable() && b.Width(i) > 0 {
+			weights[i] /= b.Width(i)
+		}
+	}
+	return weights
+}
+
+// createVerticalHistogram builds a vertical bar histogram of the values stats
+// summarizes as a multiline string. It divides the range described by b into
+// bins and scales the height to opts.barHeight.
+func createVerticalHistogram(stats histogram.Stats, b histogram.Binning, opts histogramOptions) string {
+	values := stats.Sorted
+	if len(values) == 0 {
+		return "No data"
+	}
+	barHeight := opts.barHeight
+	style := opts.style
+	full := style.glyph(style.resolution())
+	if b.Min == b.Max {
+		bar := ""
+		for i := 0; i < barHeight; i++ {
+			bar += full + " "
+		}
+		return bar + "\n" + formatFloat(b.Min, opts.precision, 2)
+	}
+	binCount := b.Count
+	weights := binWeights(b, b.Counts(values), opts.density)
+	maxWeight := 0.0
+	for _, w := range weights {
+		if w > maxWeight {
+			maxWeight = w
+		}
+	}
+	// Heights are measured in glyph steps so smooth bars can end mid-cell.
+	resolution := style.resolution()
+	normalized := make([]int, binCount)
+	for i, w := range weights {
+		if w > 0 {
+			// Ensure at least a height of 1 for any non-zero count
+			normalized[i] = max(1, int((w/maxWeight)*float64(barHeight*resolution)))
+		} else {
+			normalized[i] = 0
+		}
+	}
+	var outliers []bool
+	if opts.outliers {
+		outliers, _ = outlierBins(stats, b)
+	}
+	// With -zero, the gap after the last negative bin is the baseline
+	zero := -1
+	if opts.zero {
+		zero = zeroEdge(b)
+	}
+	baseline := "│"
+	if style == barASCII {
+		baseline = "|"
+	}
+	axis := newYAxis(maxWeight, barHeight, opts)
+	var rows []string
+	if opts.marker != markerNone {
+		rows = append(rows, axis.blank()+markerRow(stats, b, opts))
+	}
+	for row := barHeight; row > 0; row-- {
+		rowStr := axis.tick(row)
+		for i, h := range normalized {
+			glyph := style.glyph(h - (row-1)*resolution)
+			if outliers != nil && outliers[i] && !opts.noColor {
+				glyph = outlierStyle.Render(glyph)
+			} else if i < zero && !opts.noColor {
+				glyph = negativeStyle.Render(glyph)
+			}
+			gap := " "
+			if i == zero-1 {
+				gap = baseline
+			}
+			rowStr += glyph + gap
+		}
+		rows = append(rows, rowStr)
+	}
+	if zero > 0 {
+		// Label the zero crossing under the baseline
+		rows = append(rows, axis.blank()+strings.Repeat(" ", 2*zero-1)+"0")
+	}
+	if outliers != nil && opts.noColor {
+		rowStr := axis.blank()
+		for _, flagged := range outliers {
+			if flagged {
+				rowStr += "! "
+			} else {
+				rowStr += "  "
+			}
+		}
+		rows = append(rows, rowStr)
+	}
+	// Build bottom label row showing the midpoints (or log-spaced edges).
+	labelRow := axis.blank() + binLabelRow(b, opts.precision)
+	if opts.overflow {
+		// Always add the row, even if empty, so panels keep the same height
+		under, over := b.Outside(values)
+		left, right := "", ""
+		if under > 0 {
+			left = fmt.Sprintf("<%d", under)
+		}
+		if over > 0 {
+			right = fmt.Sprintf(">%d", over)
+		}
+		gap := max(1, binCount*5-1-len(left)-len(right))
+		labelRow += "\n" + axis.blank() + left + strings.Repeat(" ", gap) + right
+	}
+	return strings.Join(rows, "\n") + "\n" + labelRow
+}
+
+// horizontalEighths holds the partial-width glyphs indexed by eighths filled.
+var horizontalEighths = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// createHorizontalHistogram draws one row per bin, as renderStringHistogram
+// does for strings: the bin's range, its count, and a bar, with rows fitted
+// to width cells. Markers, outliers and overflow are shown as in
+// createVerticalHistogram, as suffixes and extra rows.
+func createHorizontalHistogram(stats histogram.Stats, b histogram.Binning, opts histogramOptions, width int) string {
+	values := stats.Sorted
+	if len(values) == 0 {
+		return "No data"
+	}
+	full := opts.style.glyph(opts.style.resolution())
+	if b.Min == b.Max {
+		return fmt.Sprintf("%s %d %s", formatFloat(b.Min, opts.precision, 2), len(values), full)
+	}
+
+	weights := binWeights(b, b.Counts(values), opts.density)
+	maxWeight := 0.0
+	for _, w := range weights {
+		maxWeight = math.Max(maxWeight, w)
+	}
+	var outliers []bool
+	if opts.outliers {
+		outliers, _ = outlierBins(stats, b)
+	}
+	meanBin, medianBin := markerBins(stats, b, opts.marker)
+
+	// Label and count columns are padded to their widest entries
+	los := make([]string, b.Count)
+	his := make([]string, b.Count)
+	counts := make([]string, b.Count)
+	loWidth, hiWidth, countWidth := 0, 0, 0
+	for i, w := range weights {
+		los[i] = formatFloat(b.Edge(i), opts.precision, 1)
+		his[i] = formatFloat(b.Edge(i+1), opts.precision, 1)
+		counts[i] = strconv.Itoa(int(w))
+		if opts.density {
+			counts[i] = formatFloat(w, opts.precision, 2)
+		}
+		loWidth = max(loWidth, len(los[i]))
+		hiWidth = max(hiWidth, len(his[i]))
+		countWidth = max(countWidth, len(counts[i]))
+	}
+	// Leave room for the " !" and marker suffixes
+	suffixWidth := 0
+	if outliers != nil && opts.noColor {
+		suffixWidth += 2
+	}
+	if opts.marker != markerNone {
+		suffixWidth += 2
+	}
+	barWidth := max(1, width-loWidth-hiWidth-countWidth-5-suffixWidth)
+
+	meanGlyph, medianGlyph, bothGlyph := "◀", "◁", "◆"
+	if opts.style == barASCII {
+		meanGlyph, medianGlyph, bothGlyph = "<", "m", "*"
+	}
+
+	// With -zero, a rule marked 0 separates the negative bins' rows from
+	// the rest
+	zero := -1
+	if opts.zero {
+		zero = zeroEdge(b)
+	}
+	rule := "─"
+	if opts.style == barASCII {
+		rule = "-"
+	}
+
+	rows := make([]string, 0, b.Count+3)
+	for i, w := range weights {
+		if i == zero {
+			rows = append(rows, strings.Repeat(rule, loWidth+hiWidth+countWidth+5)+"0")
+		}
+		// Bar lengths are measured in eighths when smooth bars are on
+		resolution := 1
+		if opts.style == barSmooth {
+			resolution = len(horizontalEighths)
+		}
+		steps := 0
+		if w > 0 {
+			steps = max(1, int(w/maxWeight*float64(barWidth*resolution)))
+		}
+		bar := strings.Repeat(full, steps/resolution)
+		if opts.style == barSmooth {
+			bar += horizontalEighths[steps%resolution]
+		}
+		if outliers != nil && outliers[i] {
+			if opts.noColor {
+				bar += " !"
+			} else {
+				bar = outlierStyle.Render(bar)
+			}
+		} else if i < zero && !opts.noColor {
+			bar = negativeStyle.Render(bar)
+		}
+		switch {
+		case i == meanBin && i == medianBin:
+			bar += " " + bothGlyph
+		case i == meanBin:
+			bar += " " + meanGlyph
+		case i == medianBin:
+			bar += " " + medianGlyph
+		}
+		rows = append(rows, fmt.Sprintf("%*s - %*s %*s %s", loWidth, los[i], hiWidth, his[i], countWidth, counts[i], bar))
+	}
+	if opts.overflow {
+		// Always add the rows, even if empty, so panels keep the same height
+		under, over := b.Outside(values)
+		rows = append(rows, fmt.Sprintf("%*s %d", loWidth+hiWidth+3, "<"+formatFloat(b.Min, opts.precision, 1), under))
+		rows = append(rows, fmt.Sprintf("%*s %d", loWidth+hiWidth+3, ">"+formatFloat(b.Max, opts.precision, 1), over))
+	}
+	return strings.Join(rows, "\n")
+}
+
+// scientificRange reports whether axis labels for [gmin, gmax] need
+// scientific notation: values reaching a million, or all below a thousandth,
+// would otherwise be walls of digits or zeros.
+func scientificRange(gmin, gmax float64) bool {
+	largest := math.Max(math.Abs(gmin), math.Abs(gmax))
+	return largest >= 1e6 || (largest > 0 && largest < 1e-3)
+}
+
+// formatScientific formats v in compact scientific notation, like 1.2e6 or
+// 3.5e-9, with precision mantissa decimals, or def if precision is negative.
+func formatScientific(v float64, precision, def int) string {
+	if precision < 0 {
+		precision = def
+	}
+	if v == 0 {
+		return "0"
+	}
+	mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(v, 'e', precision, 64), "e")
+	exp, _ := strconv.Atoi(exponent)
+	return mantissa + "e" + strconv.Itoa(exp)
+}
+
+// formatAxis formats an axis label, in scientific notation if the axis's
+// range calls for it (see scientificRange) and with formatFloat otherwise.
+func formatAxis(v float64, scientific bool, precision, def int) string {
+	if scientific {
+		return formatScientific(v, precision, def)
+	}
+	return formatFloat(v, precision, def)
+}
+
+// labelDecimals returns how many decimals the bin labels of b need to tell
+// neighboring bins apart, from the narrowest bin's width.
+func labelDecimals(b histogram.Binning) int {
+	step := b.Width(0)
+	for i := 1; b.Variable() && i < b.Count; i++ {
+		step = math.Min(step, b.Width(i))
+	}
+	if step <= 0 || math.IsInf(step, 0) || math.IsNaN(step) {
+		return 1
+	}
+	return max(0, min(3, 1-int(math.Floor(math.Log10(step)))))
+}
+
+// binLabelRow lays out the bin labels under a vertical histogram, in five
+// columns per bin. Labels share one decimal count and width, so they line up
+// on the decimal point; when they're wider than a bin, only every k-th bin is
+// labeled so they don't run together.
+func binLabelRow(b histogram.Binning, precision int) string {
+	scientific := scientificRange(b.Min, b.Max)
+	if precision < 0 && !scientific {
+		precision = labelDecimals(b)
+	}
+	labels := make([]string, b.Count)
+	width := 0
+	for i := range labels {
+		labels[i] = formatAxis(b.Label(i), scientific, precision, 1)
+		width = max(width, len(labels[i]))
+	}
+
+	const binWidth = 5
+	rowWidth := b.Count*binWidth - 1
+	every := (width + binWidth) / binWidth // ceil((width+1)/binWidth)
+	row := []byte(strings.Repeat(" ", rowWidth))
+	for i := 0; i < b.Count; i += every {
+		start := i * binWidth
+		if start+width > rowWidth {
+			break
+		}
+		copy(row[start:], fmt.Sprintf("%*s", width, labels[i]))
+	}
+	return string(row)
+}
+
+// markerBins returns the bins containing the mean and median that mode
+// marks, or -1 for a marker that isn't drawn.
+func markerBins(stats histogram.Stats, b histogram.Binning, mode markerMode) (meanBin, medianBin int) {
+	meanBin, medianBin = -1, -1
+	if mode == markerMean || mode == markerBoth {
+		meanBin = b.Index(stats.Mean)
+	}
+	if mode == markerMedian || mode == markerBoth {
+		medianBin = b.Index(stats.Percentile(50))
+	}
+	return meanBin, medianBin
+}
+
+// markerRow builds the row drawn above the bars that points at the bins
+// containing the mean (▼) and median (▽); ◆ marks a bin holding both.
+func markerRow(stats histogram.Stats, b histogram.Binning, opts histogramOptions) string {
+	meanGlyph, medianGlyph, bothGlyph := "▼", "▽", "◆"
+	if opts.style == barASCII {
+		meanGlyph, medianGlyph, bothGlyph = "v", "m", "*"
+	}
+
+	meanBin, medianBin := markerBins(stats, b, opts.marker)
+
+	var row strings.Builder
+	for i := 0; i < b.Count; i++ {
+		switch {
+		case i == meanBin && i == medianBin:
+			row.WriteString(bothGlyph)
+		case i == meanBin:
+			row.WriteString(meanGlyph)
+		case i == medianBin:
+			row.WriteString(medianGlyph)
+		default:
+			row.WriteString(" ")
+		}
+		row.WriteString(" ")
+	}
+	return row.String()
+}
+
+// yAxis renders the left margin of a vertical histogram. Labels are shown
+// on the top, middle, and bottom rows and padded to the width of the widest
+// label so the bars stay aligned as counts grow.
+type yAxis struct {
+	enabled   bool
+	maxWeight float64
+	barHeight int
+	density   bool
+	precision int
+	line      string
+	width     int
+}
+
+// newYAxis returns the y-axis for a histogram whose tallest bar is maxWeight.
+func newYAxis(maxWeight float64, barHeight int, opts histogramOptions) yAxis {
+	a := yAxis{
+		enabled:   opts.yAxis,
+		maxWeight: maxWeight,
+		barHeight: barHeight,
+		density:   opts.density,
+		precision: opts.precision,
+		line:      "│",
+	}
+	if opts.style == barASCII {
+		a.line = "|"
+	}
+	if a.enabled {
+		a.width = len(a.label(barHeight))
+	}
+	return a
+}
+
+// label formats the count (or density) that the top of the given row represents.
+func (a yAxis) label(row int) string {
+	v := a.maxWeight * float64(row) / float64(a.barHeight)
+	if a.density {
+		return formatFloat(v, a.precision, 2)
+	}
+	return fmt.Sprintf("%d", int(math.Round(v)))
+}
+
+// tick returns the margin for a bar row, including its label if it has one.
+func (a yAxis) tick(row int) string {
+	if !a.enabled {
+		return ""
+	}
+	if row == a.barHeight || row == 1 || row == (a.barHeight+1)/2 {
+		return fmt.Sprintf("%*s%s ", a.width, a.label(row), a.line)
+	}
+	return strings.Repeat(" ", a.width) + a.line + " "
+}
+
+// blank returns margin padding for rows below the bars.
+func (a yAxis) blank() string {
+	if !a.enabled {
+		return ""
+	}
+	return strings.Repeat(" ", a.width+2)
+}
+
+// -------------------------
+// Rendering Functions
+// -------------------------
+
+// renderHeader creates a header string showing log rate, total count, and pin status.
+func (m model) renderHeader() string {
+	elapsed := time.Since(m.startTime).Seconds()
+	// With a sliding window, the count only covers the window's duration
+	if m.window > 0 {
+		elapsed = math.Min(elapsed, m.window.Seconds())
+	}
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(m.totalLogCount) / elapsed
+	}
+
+	header := fmt.Sprintf("Log Rate: %s logs/sec | Total Logs: %d", formatFloat(rate, m.precision, 2), m.totalLogCount)
+	// The average hides bursts, so lead with the rate over the last few seconds
+	if current, ok := m.recent.rate(); ok {
+		header = fmt.Sprintf("Log Rate: %s logs/sec now, %s avg | Total Logs: %d",
+			formatFloat(current, m.precision, 2), formatFloat(rate, m.precision, 2), m.totalLogCount)
+	}
+	if m.missingCount > 0 {
+		header += fmt.Sprintf(" | Missing: %d", m.missingCount)
+	}
+	if m.scaleSkipped > 0 {
+		header += fmt.Sprintf(" | Skipped by -scale: %d", m.scaleSkipped)
+	}
+	if m.showExtremes {
+		if gmin, gmax, _, ok := m.globalStats(); ok {
+			minKeys, maxKeys := m.globalExtremes()
+			header += fmt.Sprintf(" | Max: %s%s Min: %s%s",
+				formatFloat(gmax, m.precision, 2), extremeKeys(maxKeys),
+				formatFloat(gmin, m.precision, 2), extremeKeys(minKeys))
+		}
+	}
+
+	// The sum of the values suits size metrics (bytes/sec and the like)
+	if total := m.valueTotal(); total != 0 {
+		valueRate := 0.0
+		if elapsed > 0 {
+			valueRate = total / elapsed
+		}
+		header += fmt.Sprintf(" | Value Total: %s (%s/sec)", formatFloat(total, m.precision, 2), formatFloat(valueRate, m.precision, 2))
+	}
+
+	if m.paused {
+		header = fmt.Sprintf("PAUSED (%d buffered) | ", m.pendingLines) + header
+	}
+
+	sortInfo := " | Sort: " + m.sortMode.String()
+	if m.sortReverse {
+		sortInfo += " (reversed)"
+	}
+	if m.stableOrder != nil {
+		sortInfo += " (stable, R to re-sort)"
+	}
+	header += sortInfo
+
+	if m.facet > 0 {
+		header += fmt.Sprintf(" | Column %d of %d", m.facet, m.maxFacet())
+	}
+
+	// Add information about pinned facets if any
+	if m.isFiltered && len(m.pinnedFacets) > 0 {
+		pinnedInfo := " | Pins: "
+		first := true
+		for facet, isPinned := range m.pinnedFacets {
+			if isPinned {
+				if !first {
+					pinnedInfo += ", "
+				}
+				col := m.pinnedFacetsColumn[facet]
+				pinnedInfo += fmt.Sprintf("%d:%s", col, facet)
+				first = false
+			}
+		}
+		header += pinnedInfo
+	}
+	if len(m.excludedFacets) > 0 {
+		excluded := make([]string, 0, len(m.excludedFacets))
+		for facet := range m.excludedFacets {
+			excluded = append(excluded, fmt.Sprintf("%d:%s", m.excludedFacetsColumn[facet], facet))
+		}
+		sort.Strings(excluded)
+		header += " | Excludes: " + strings.Join(excluded, ", ")
+	}
+
+	if m.perFacetScale {
+		header += " | Scale: per-facet"
+	}
+	if m.window > 0 {
+		header += fmt.Sprintf(" | Window: %s", m.window)
+	}
+	if m.density {
+		header += " | Density"
+	}
+	if m.outliers {
+		header += " | Outliers"
+	}
+	if m.showDiff && m.baseline != nil {
+		header += " | Diff vs baseline"
+	}
+	if m.ewmaAlpha > 0 {
+		alpha := "α"
+		if m.ascii {
+			alpha = "alpha"
+		}
+		header += fmt.Sprintf(" | EWMA %s=%g (half-life %s samples)", alpha, m.ewmaAlpha,
+			formatFloat(ewmaHalfLife(m.ewmaAlpha), m.precision, 1))
+	}
+	if m.searchQuery != "" {
+		header += fmt.Sprintf(" | Filter: %q", m.searchQuery)
+	}
+	if m.minCount > 0 {
+		if m.showSparse {
+			header += fmt.Sprintf(" | Min count: %d (dimmed)", m.minCount)
+		} else if hidden := m.hiddenSparseKeys(); hidden > 0 {
+			header += fmt.Sprintf(" | Min count: %d (%d keys hidden)", m.minCount, hidden)
+		}
+	}
+
+	if m.compareWith.key != "" {
+		header += fmt.Sprintf(" | Compare: %s vs %s", m.compareMark.key, m.compareWith.key)
+	} else if m.compareMark.key != "" {
+		header += fmt.Sprintf(" | Marked: %s (m on another key to compare)", m.compareMark.key)
+	}
+	if m.statusMessage != "" {
+		header += " | " + m.statusMessage
+	}
+
+	if m.noColor {
+		return header
+	}
+	return lipgloss.NewStyle().
+		Background(lipgloss.Color("4")).
+		Foreground(lipgloss.Color("15")).
+		Bold(true).
+		Render(header)
+}
+
+// wrapText wraps text to a specified display width, preserving words when possible
+func wrapText(text string, width int, maxHeight int) string {
+	if width <= 0 || runewidth.StringWidth(text) <= width {
+		return text
+	}
+
+	var wrapped strings.Builder
+	words := strings.Fields(text)
+
+	lineCount := 0
+	line := ""
+	for _, word := range words {
+		if runewidth.StringWidth(line)+runewidth.StringWidth(word)+1 <= width {
+			if line != "" {
+				line += " "
+			}
+			line += word
+		} else {
+			if line != "" {
+				wrapped.WriteString(line + "\n")
+				lineCount++
+
+				// Check if we've reached maxHeight
 				if maxHeight > 0 && lineCount >= maxHeight {
 					return wrapped.String() + "..."
 				}
 			}
-			// If a single word is longer than width, we need to force-break it
-			if len(word) > width {
-				for len(word) > 0 {
-					if len(word) <= width {
-						line = word
-						break
-					}
-					wrapped.WriteString(word[:width] + "\n")
-					lineCount++
+			// If a single word is longer than width, we need to force-break it
+			if runewidth.StringWidth(word) > width {
+				for len(word) > 0 {
+					if runewidth.StringWidth(word) <= width {
+						line = word
+						break
+					}
+					head, tail := splitAtWidth(word, width)
+					wrapped.WriteString(head + "\n")
+					lineCount++
+
+					// Check if we've reached maxHeight
+					if maxHeight > 0 && lineCount >= maxHeight {
+						return wrapped.String() + "..."
+					}
+
+					word = tail
+				}
+			} else {
+				line = word
+			}
+		}
+	}
+
+	if line != "" {
+		wrapped.WriteString(line)
+	}
+
+	return wrapped.String()
+}
+
+// splitAtWidth splits s on a rune boundary so that head fills at most width
+// display cells. head always holds at least one rune so callers make progress.
+func splitAtWidth(s string, width int) (head, tail string) {
+	cells := 0
+	for i, r := range s {
+		w := runewidth.RuneWidth(r)
+		if cells+w > width && i > 0 {
+			return s[:i], s[i:]
+		}
+		cells += w
+	}
+	return s, ""
+}
+
+// renderSingleFacet builds panels for a single facet column and arranges them in a grid.
+func (m model) renderSingleFacet() string {
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+
+	gmin, gmax, found := m.globalRange()
+	if !found {
+		return "No data yet."
+	}
+
+	facetData, ok := dataSource[m.facet]
+	if !ok {
+		return "Facet not available yet."
+	}
+
+	// Build a slice of keys and sort them by descending mean.
+	keys := m.visibleFacetKeys(m.facet, facetData)
+
+	// Constants for consistent panel dimensions
+	const maxKeyWidth = 64 // Maximum width for facet keys before wrapping
+	const maxKeyHeight = 3 // Maximum height for wrapped facet keys
+	const plotWidth = 70   // Width limit for the entire plot
+
+	histOpts := m.histogramOptions(m.effectiveBarHeight())
+	// Each bin takes a five-character label; leave room for the panel chrome
+	allValues := m.allValues()
+	binCount := m.binCountFor(allValues, 10, max(1, (m.renderWidth()-8)/5))
+	bins := m.binningFor(allValues, gmin, gmax, binCount)
+
+	// Check if any titles wrap to two lines by wrapping all titles first
+	wrappedTitles := make([]string, len(keys))
+	anyWrapped := false
+	for i, key := range keys {
+		marker, _ := m.keyMarker(key)
+		displayKey := marker + key
+		wrappedTitles[i] = wrapText(displayKey, maxKeyWidth, maxKeyHeight)
+		if strings.Contains(wrappedTitles[i], "\n") {
+			anyWrapped = true
+		}
+	}
+	// If any title wrapped to two lines, ensure all titles have at least two lines
+	// by adding an extra newline to single-line titles
+	if anyWrapped {
+		for i, title := range wrappedTitles {
+			if !strings.Contains(title, "\n") {
+				wrappedTitles[i] = title + "\n"
+			}
+		}
+	}
+
+	renderPanel := func(i int) string {
+		key := keys[i]
+		values := facetData[key]
+		var content string
+		if m.excludedFacets[key] {
+			content = "Excluded"
+		} else if m.boxPlot {
+			pmin, pmax := gmin, gmax
+			if m.perFacetScale {
+				pmin, pmax, _ = valueRange(values)
+			}
+			// Match the width of the histogram label row so panels line up
+			content = renderBoxPlot(m.keyStats(values), pmin, pmax, bins.Count*5-1, m.precision)
+			if m.ascii {
+				content = asciiBoxReplacer.Replace(content)
+			}
+		} else if m.stats {
+			mean, stdev := m.keyMeanStdev(m.facet, key, values)
+			meanText := formatFloat(mean, m.precision, 2)
+			if m.confidence {
+				meanText += " " + m.formatConfidence(stdev, len(values)) + " (95% CI)"
+			}
+			content = fmt.Sprintf("Mean: %s\nStd Dev: %s\nCount: %d",
+				meanText, formatFloat(stdev, m.precision, 2), len(values))
+			if m.geomean {
+				content += "\nGeo Mean: " + m.formatGeomean(values)
+			}
+			if m.trim > 0 {
+				content += fmt.Sprintf("\nTrimmed Mean (%s): %s", m.trimPercent(),
+					formatFloat(m.keyTrimmedMean(values), m.precision, 2))
+			}
+			if m.outliers {
+				_, count := outlierBins(m.keyStats(values), bins)
+				content += fmt.Sprintf("\nOutliers: %d", count)
+			}
+		} else if m.perFacetScale {
+			// Scale this panel to its own range and label it accordingly
+			kmin, kmax, _ := valueRange(values)
+			keyBins := m.binningFor(values, kmin, kmax, bins.Count)
+			if m.horizontal {
+				content = createHorizontalHistogram(m.keyStats(values), keyBins, histOpts, bins.Count*5-1)
+			} else {
+				content = createVerticalHistogram(m.keyStats(values), keyBins, histOpts)
+			}
+			content += fmt.Sprintf("\nRange: %s - %s", formatFloat(kmin, m.precision, 2), formatFloat(kmax, m.precision, 2))
+		} else if m.horizontal {
+			content = createHorizontalHistogram(m.keyStats(values), bins, histOpts, bins.Count*5-1)
+		} else {
+			content = createVerticalHistogram(m.keyStats(values), bins, histOpts)
+		}
+
+		// The line under the title notes how much data backs the shape; the
+		// stats already show the count
+		subtitle := ""
+		if !m.stats && !m.excludedFacets[key] {
+			mean, _ := m.keyMeanStdev(m.facet, key, values)
+			subtitle = m.panelSubtitle(len(values), mean, bins.Count*5-1)
+		}
+
+		// Keys under -min-count, shown on demand, are dimmed
+		title := wrappedTitles[i]
+		if m.minCount > 0 && m.sparse(key, values) && !m.noColor {
+			title = sparseStyle.Render(title)
+			subtitle = sparseStyle.Render(subtitle)
+		}
+
+		// Render the panel with wrapped text, styled by active and pinned status
+		style := m.panelStyleFor(key == m.activeFacet, m.pinnedFacets[key])
+		return style.Render(fmt.Sprintf("%s\n%s\n%s", title, subtitle, content))
+	}
+
+	if len(keys) == 0 {
+		return ""
+	}
+
+	// Panels are only rendered for rows near the visible window; the rest
+	// are stood in for by blank lines of the same height, so the scroll
+	// range and mouse regions match a fully drawn grid. Excluded panels are
+	// cheap and drawn up front, along with one full panel to measure.
+	panels := make([]string, len(keys))
+	panels[0] = renderPanel(0)
+	sample := -1
+	for i, key := range keys {
+		if m.excludedFacets[key] {
+			if panels[i] == "" {
+				panels[i] = renderPanel(i)
+			}
+		} else if sample < 0 {
+			sample = i
+			if panels[i] == "" {
+				panels[i] = renderPanel(i)
+			}
+		}
+	}
+	titleHeight := func(i int) int { return strings.Count(wrappedTitles[i], "\n") + 1 }
+	// Apart from excluded ones, panels differ in height only by their titles
+	var bodyHeight int
+	if sample >= 0 {
+		bodyHeight = lipgloss.Height(panels[sample]) - titleHeight(sample)
+	}
+	panelHeight := func(i int) int {
+		if panels[i] != "" {
+			return lipgloss.Height(panels[i])
+		}
+		return bodyHeight + titleHeight(i)
+	}
+
+	// Calculate grid layout for navigation
+	// Use real panel width to determine columns that fit
+	panelWidth := lipgloss.Width(panels[0])
+	columns := max(1, m.renderWidth()/max(1, panelWidth))
+	m.gridColumns = columns
+
+	// Rows are as tall as their tallest panel
+	var rowHeights []int
+	total := 0
+	for rowStart := 0; rowStart < len(keys); rowStart += columns {
+		height := 0
+		for i := rowStart; i < min(rowStart+columns, len(keys)); i++ {
+			height = max(height, panelHeight(i))
+		}
+		rowHeights = append(rowHeights, height)
+		total += height
+	}
+
+	// View clamps the scroll offset to the content, so the visible window
+	// starts no later than the last screenful; render one extra row on each
+	// side of it
+	const overscan = 1
+	windowTop := max(0, min(m.scrollOffset, total-m.winHeight))
+	windowBottom := m.scrollOffset + m.winHeight
+	firstRow, lastRow := len(rowHeights), -1
+	top := 0
+	for r, height := range rowHeights {
+		if top+height > windowTop && top < windowBottom {
+			firstRow = min(firstRow, r)
+			lastRow = r
+		}
+		top += height
+	}
+	firstRow -= overscan
+	lastRow += overscan
+
+	rows := make([]string, len(rowHeights))
+	top = 0
+	for r, height := range rowHeights {
+		rowStart := r * columns
+		rowEnd := min(rowStart+columns, len(keys))
+		if r < firstRow || r > lastRow {
+			rows[r] = strings.Repeat("\n", height-1)
+			top += height
+			continue
+		}
+		left := 0
+		for i := rowStart; i < rowEnd; i++ {
+			if panels[i] == "" {
+				panels[i] = renderPanel(i)
+			}
+			width := lipgloss.Width(panels[i])
+			m.layout.record(keys[i], top, top+lipgloss.Height(panels[i]), left, left+width)
+			left += width
+		}
+		rows[r] = lipgloss.JoinHorizontal(lipgloss.Top, panels[rowStart:rowEnd]...)
+		top += height
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Top, rows...)
+}
+
+// stackGlyphs and asciiStackGlyphs tell keys apart when color is disabled.
+var (
+	stackGlyphs      = []string{"█", "▓", "▒", "░", "▞", "▚", "▖", "▗"}
+	asciiStackGlyphs = []string{"#", "@", "%", "*", "+", "=", "-", ":"}
+)
+
+// stackOtherColor and stackOtherGlyph draw the keys beyond the named ones.
+const (
+	stackOtherColor = "240"
+	stackOtherGlyph = "·"
+)
+
+// stackSlots assigns each key a distinct key color slot. A key starts at its
+// keySlot, probing past slots already taken, so it keeps its colorForKey
+// unless it shares that slot with another key in view.
+func stackSlots(keys []string) map[string]int {
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	slots := make(map[string]int, len(sorted))
+	taken := make([]bool, keyColorCount)
+	for _, key := range sorted {
+		slot := keySlot(key)
+		for taken[slot] {
+			slot = (slot + 1) % keyColorCount
+		}
+		taken[slot] = true
+		slots[key] = slot
+	}
+	return slots
+}
+
+// stackCell renders one cell of the stacked histogram for the given slot, or
+// for the "other" keys if slot is negative.
+func (m model) stackCell(slot int) string {
+	switch {
+	case m.noColor && slot < 0:
+		if m.ascii {
+			return "."
+		}
+		return stackOtherGlyph
+	case m.ascii:
+		return asciiStackGlyphs[slot]
+	case m.noColor:
+		return stackGlyphs[slot]
+	}
+	color := lipgloss.Color(stackOtherColor)
+	if slot >= 0 {
+		color = m.keyColor(slot)
+	}
+	return lipgloss.NewStyle().Foreground(color).Render("█")
+}
+
+// renderStacked draws the current facet column as a single histogram whose
+// bars are split into per-key segments, showing how each bin's total is
+// composed. The first keys in sort order get their own color; the rest are
+// combined as "other".
+func (m model) renderStacked() string {
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+	gmin, gmax, found := m.globalRange()
+	if !found {
+		return "No data yet."
+	}
+	facetData, ok := dataSource[m.facet]
+	if !ok {
+		return "Facet not available yet."
+	}
+
+	keys := m.visibleFacetKeys(m.facet, facetData)
+	named := keys
+	if len(named) > keyColorCount {
+		named = keys[:keyColorCount]
+	}
+	slots := stackSlots(named)
+
+	allValues := m.allValues()
+	binCount := m.binCountFor(allValues, 10, max(1, (m.renderWidth()-8)/5))
+	bins := m.binningFor(allValues, gmin, gmax, binCount)
+	barHeight := m.effectiveBarHeight()
+
+	// Per-bin counts for each named key, plus everything else
+	layers := make([][]int, 0, len(named)+1)
+	layerSlots := make([]int, 0, len(named)+1)
+	for _, key := range named {
+		layers = append(layers, bins.Counts(facetData[key]))
+		layerSlots = append(layerSlots, slots[key])
+	}
+	other := make([]int, bins.Count)
+	for _, key := range keys[len(named):] {
+		for i, c := range bins.Counts(facetData[key]) {
+			other[i] += c
+		}
+	}
+	layers = append(layers, other)
+	layerSlots = append(layerSlots, -1)
+
+	totals := make([]int, bins.Count)
+	maxTotal := 0
+	for _, layer := range layers {
+		for i, c := range layer {
+			totals[i] += c
+		}
+	}
+	for _, t := range totals {
+		maxTotal = max(maxTotal, t)
+	}
+	if maxTotal == 0 {
+		return "No data yet."
+	}
+
+	// Each row covers a slice of the count range; its cell takes the color of
+	// the layer that spans the middle of that slice
+	var b strings.Builder
+	for row := barHeight; row > 0; row-- {
+		mid := (float64(row) - 0.5) / float64(barHeight) * float64(maxTotal)
+		for i := 0; i < bins.Count; i++ {
+			cell := " "
+			// Non-empty bins always show at least their bottom cell
+			if float64(totals[i]) > mid || (row == 1 && totals[i] > 0) {
+				cumulative, top := 0, -1
+				for l, layer := range layers {
+					if layer[i] == 0 {
+						continue
+					}
+					cumulative += layer[i]
+					top = l
+					if float64(cumulative) > mid {
+						break
+					}
+				}
+				cell = m.stackCell(layerSlots[top])
+			}
+			b.WriteString(cell + " ")
+		}
+		b.WriteString("\n")
+	}
+	var labels []string
+	for i := 0; i < bins.Count; i++ {
+		labels = append(labels, fmt.Sprintf("%4s", formatFloat(bins.Label(i), m.precision, 1)))
+	}
+	b.WriteString(strings.Join(labels, " ") + "\n\n")
+
+	// Legend mapping colors (or glyphs) to keys
+	for _, key := range named {
+		marker, _ := m.keyMarker(key)
+		b.WriteString(fmt.Sprintf("%s %s%s n=%d\n", m.stackCell(slots[key]), marker, key, len(facetData[key])))
+	}
+	if rest := len(keys) - len(named); rest > 0 {
+		n := 0
+		for _, c := range other {
+			n += c
+		}
+		b.WriteString(fmt.Sprintf("%s %d other keys n=%d\n", m.stackCell(-1), rest, n))
+	}
+	return b.String()
+}
+
+// renderHeatmap draws the current facet column as a grid with one row per
+// key and one column per bin, colored by count on a scale shared by every
+// row, followed by a colorbar.
+func (m model) renderHeatmap() string {
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+	gmin, gmax, found := m.globalRange()
+	if !found {
+		return "No data yet."
+	}
+	facetData, ok := dataSource[m.facet]
+	if !ok {
+		return "Facet not available yet."
+	}
+	keys := m.visibleFacetKeys(m.facet, facetData)
+
+	keyWidth := 10
+	for _, key := range keys {
+		_, markerWidth := m.keyMarker(key)
+		keyWidth = max(keyWidth, runewidth.StringWidth(key)+markerWidth)
+	}
+
+	// Cells are two columns wide; leave room for the key column and totals
+	allValues := m.allValues()
+	binCount := m.binCountFor(allValues, 20, max(1, (m.renderWidth()-keyWidth-14)/2))
+	bins := m.binningFor(allValues, gmin, gmax, binCount)
+
+	rows := make([][]int, len(keys))
+	maxCount := 0
+	for i, key := range keys {
+		rows[i] = bins.Counts(facetData[key])
+		for _, c := range rows[i] {
+			maxCount = max(maxCount, c)
+		}
+	}
+
+	var b strings.Builder
+	indent := strings.Repeat(" ", keyWidth+2)
+
+	// Bin edge labels every five cells, as in the all-facets view
+	b.WriteString(indent)
+	for i := 0; i < bins.Count; i += 5 {
+		b.WriteString(fmt.Sprintf("%-10s", formatFloat(bins.Edge(i), m.precision, 1)))
+	}
+	b.WriteString("\n")
+
+	ramp := m.intensityRamp()
+	for i, key := range keys {
+		lead := m.activeLead(key == m.activeFacet)
+		marker, markerWidth := m.keyMarker(key)
+		b.WriteString(lead + marker + runewidth.FillRight(key, keyWidth-markerWidth))
+		m.layout.record(key, i+1, i+2, 0, m.winWidth) // below the bin labels
+		for _, count := range rows[i] {
+			if count == 0 {
+				b.WriteString("  ")
+				continue
+			}
+			// Log scale as in the all-facets view, shared across rows
+			normalized := m.cellIntensity(count, maxCount)
+			if ramp != nil {
+				level := 1 + int(normalized*float64(len(ramp)-2))
+				b.WriteString(strings.Repeat(ramp[min(level, len(ramp)-1)], 2))
+				continue
+			}
+			b.WriteString(lipgloss.NewStyle().
+				Background(lipgloss.Color(fmt.Sprintf("%d", m.palette.color(normalized)))).
+				Render("  "))
+		}
+		b.WriteString(fmt.Sprintf(" n=%d\n", len(facetData[key])))
+	}
+
+	// Colorbar with the count range it spans
+	b.WriteString("\n" + indent + "count: 1 ")
+	b.WriteString(renderColorGradient(ramp, m.palette, m.reverseColors))
+	b.WriteString(fmt.Sprintf(" %d (log scale)", maxCount))
+	return b.String()
+}
+
+// compareColors returns the colors of the two keys in compare mode: the
+// first is drawn as a foreground glyph, the second as a background color.
+// Each key has its colorForKey unless both hash to the same one, in which
+// case the second takes the next slot.
+func (m model) compareColors() [2]lipgloss.Color {
+	a, b := keySlot(m.compareMark.key), keySlot(m.compareWith.key)
+	if a == b {
+		b = (b + 1) % keyColorCount
+	}
+	return [2]lipgloss.Color{m.colorForKey(m.compareMark.key), m.keyColor(b)}
+}
+
+// compareCell renders one overlay cell for the given presence of each key's
+// bar, in the keys' colors.
+func (m model) compareCell(a, b bool, colors [2]lipgloss.Color) string {
+	if m.noColor {
+		glyphs := [4]string{" ", "░", "█", "▓"} // neither, B, A, both
+		if m.ascii {
+			glyphs = [4]string{" ", ".", "#", "@"}
+		}
+		i := 0
+		if a {
+			i += 2
+		}
+		if b {
+			i++
+		}
+		return glyphs[i]
+	}
+
+	style := lipgloss.NewStyle()
+	glyph := " "
+	if a {
+		style = style.Foreground(colors[0])
+		glyph = "█"
+		if b {
+			glyph = "▓" // let the background show through
+		}
+	}
+	if b {
+		style = style.Background(colors[1])
+	}
+	return style.Render(glyph)
+}
+
+// renderCompare overlays the histograms of the two keys selected in compare
+// mode on a shared axis, with a caption comparing their mean and p99.
+func (m model) renderCompare() string {
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+	refs := [2]facetRef{m.compareMark, m.compareWith}
+	var values [2][]float64
+	for i, ref := range refs {
+		values[i] = dataSource[ref.column][ref.key]
+		if len(values[i]) == 0 {
+			return fmt.Sprintf("No data for %s (facet %d). Press m to leave compare mode.", ref.key, ref.column)
+		}
+	}
+
+	// Both keys share the bins and the vertical scale
+	combined := append(append([]float64(nil), values[0]...), values[1]...)
+	gmin, gmax, _ := valueRange(combined)
+	binCount := m.binCountFor(combined, 10, max(1, (m.renderWidth()-8)/5))
+	bins := m.binningFor(combined, gmin, gmax, binCount)
+	opts := m.histogramOptions(m.effectiveBarHeight())
+
+	// In density mode each key is normalized to unit area, so keys with very
+	// different sample counts can be compared by shape
+	var weights [2][]float64
+	maxWeight := 0.0
+	for i := range values {
+		weights[i] = binWeights(bins, bins.Counts(values[i]), opts.density)
+		for _, w := range weights[i] {
+			maxWeight = math.Max(maxWeight, w)
+		}
+	}
+	axis := newYAxis(maxWeight, opts.barHeight, opts)
+
+	// A bin's bar reaches a row if its scaled height does; any non-empty bin
+	// is at least one row tall
+	reaches := func(w float64, row int) bool {
+		h := int(math.Round(w / maxWeight * float64(opts.barHeight)))
+		return w > 0 && max(1, h) >= row
+	}
+
+	colors := m.compareColors()
+	var b strings.Builder
+	for row := opts.barHeight; row > 0; row-- {
+		b.WriteString(axis.tick(row))
+		for i := 0; i < bins.Count; i++ {
+			b.WriteString(m.compareCell(reaches(weights[0][i], row), reaches(weights[1][i], row), colors) + " ")
+		}
+		b.WriteString("\n")
+	}
+	var labels []string
+	for i := 0; i < bins.Count; i++ {
+		labels = append(labels, fmt.Sprintf("%4s", formatFloat(bins.Label(i), m.precision, 1)))
+	}
+	b.WriteString(axis.blank() + strings.Join(labels, " ") + "\n")
+	if opts.density {
+		b.WriteString("y: density (each key normalized to unit area; n toggles)\n\n")
+	} else {
+		b.WriteString("y: count (n normalizes each key to unit area)\n\n")
+	}
+
+	// Caption: per-key summary, then the differences B - A
+	var means, p99s [2]float64
+	for i, ref := range refs {
+		stats := m.keyStats(values[i])
+		means[i] = stats.Mean
+		p99s[i] = stats.Percentile(99)
+		b.WriteString(fmt.Sprintf("%s %s (facet %d): n=%d mean=%s p99=%s\n",
+			m.compareCell(i == 0, i == 1, colors), ref.key, ref.column, len(values[i]),
+			formatFloat(means[i], m.precision, 2), formatFloat(p99s[i], m.precision, 2)))
+	}
+	b.WriteString(fmt.Sprintf("B - A: mean %s, p99 %s",
+		formatDelta(means[1]-means[0], means[0], m.precision), formatDelta(p99s[1]-p99s[0], p99s[0], m.precision)))
+	return b.String()
+}
+
+// formatDelta formats a difference with its sign and, when the base is
+// nonzero, the relative change.
+func formatDelta(delta, base float64, precision int) string {
+	text := formatFloat(delta, precision, 2)
+	if delta >= 0 {
+		text = "+" + text
+	}
+	if base != 0 {
+		text += fmt.Sprintf(" (%+.1f%%)", delta/math.Abs(base)*100)
+	}
+	return text
+}
+
+// renderBoxPlot draws a three-line horizontal box plot of the values stats
+// summarizes (min, Q1, median, Q3, max) scaled to [gmin, gmax] across width
+// cells, followed by an axis line and the quartile values, formatted with
+// formatFloat's precision.
+func renderBoxPlot(stats histogram.Stats, gmin, gmax float64, width, precision int) string {
+	if stats.Count == 0 {
+		return "No data"
+	}
+	width = max(width, 3)
+
+	q1 := stats.Percentile(25)
+	median := stats.Percentile(50)
+	q3 := stats.Percentile(75)
+
+	// pos maps a value to its cell column
+	pos := func(v float64) int {
+		if gmax == gmin {
+			return 0
+		}
+		p := int((v - gmin) / (gmax - gmin) * float64(width-1))
+		return max(0, min(width-1, p))
+	}
+	pMin, pQ1, pMed, pQ3, pMax := pos(stats.Min), pos(q1), pos(median), pos(q3), pos(stats.Max)
+
+	top := []rune(strings.Repeat(" ", width))
+	mid := []rune(strings.Repeat(" ", width))
+	bot := []rune(strings.Repeat(" ", width))
+
+	// Whiskers span min to max; the box interior is cleared below
+	for x := pMin; x <= pMax; x++ {
+		mid[x] = '─'
+	}
+	mid[pMin] = '├'
+	mid[pMax] = '┤'
+
+	// Box from Q1 to Q3
+	for x := pQ1; x <= pQ3; x++ {
+		top[x] = '─'
+		bot[x] = '─'
+		mid[x] = ' '
+	}
+	top[pQ1], bot[pQ1], mid[pQ1] = '┌', '└', '│'
+	top[pQ3], bot[pQ3], mid[pQ3] = '┐', '┘', '│'
+	if pQ1 > pMin {
+		mid[pQ1] = '┤'
+	}
+	if pQ3 < pMax {
+		mid[pQ3] = '├'
+	}
+
+	// Median line through the box
+	top[pMed], mid[pMed], bot[pMed] = '┬', '│', '┴'
+
+	maxLabel := formatFloat(gmax, precision, 1)
+	axis := fmt.Sprintf("%-*s%s", max(0, width-len(maxLabel)), formatFloat(gmin, precision, 1), maxLabel)
+	quartiles := fmt.Sprintf("Q1=%s med=%s Q3=%s",
+		formatFloat(q1, precision, 2), formatFloat(median, precision, 2), formatFloat(q3, precision, 2))
+	return strings.Join([]string{string(top), string(mid), string(bot), axis, quartiles}, "\n")
+}
+
+// effectiveBarHeight returns the requested bar height clamped so that a single
+// panel still fits within the visible area of the terminal.
+func (m model) effectiveBarHeight() int {
+	// Panel chrome: border (2) + padding (2) + margin (2) + title (up to 3) +
+	// blank line (1) + label row (1), plus the header and instructions (4).
+	const reservedHeight = 15
+
+	height := m.barHeight
+	if height < 1 {
+		height = 10
+	}
+	if limit := m.winHeight - reservedHeight; height > limit {
+		height = max(1, limit)
+	}
+	return height
+}
+
+// renderMultiFacet renders a summary for all facet columns.
+func (m model) renderMultiFacet() string {
+	var output strings.Builder
+
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+
+	// First determine global min/max for consistent bucketing
+	gmin, gmax, found := m.globalRange()
+	if !found {
+		// Values are only stored per key, so a distinct-count column on its
+		// own leaves nothing to plot
+		if m.distinctColumn > 0 && (m.distinct != nil || m.filteredDistinct != nil) {
+			return m.distinctSummary()
+		}
+		return "No data yet."
+	}
+
+	// Number of buckets for histogram representation; each bucket is five
+	// characters wide, leaving room for the key column and stats
+	allValues := m.allValues()
+	cellWidth := m.cellWidth()
+	bins := m.binningFor(allValues, gmin, gmax, m.binCountFor(allValues, 20, max(1, (m.renderWidth()-40)/cellWidth)))
+	bucketCount := bins.Count
+
+	// Sort facet numbers for consistent rendering order in summary stats
+	facets := dataSource.Columns()
+
+	// Reset facet positions for navigation
+	m.facetPositions = make(map[string][2]int)
+
+	// Track row position for facet navigation
+	rowPosition := 0
+
+	// Reset active facet keys
+	m.activeFacetKeys = nil
+
+	// If the active facet isn't set yet, initialize it with the first key from sorted keys
+	// This ensures consistent navigation starting point
+	firstFacetKey := ""
+	for _, facet := range facets {
+		facetData := dataSource[facet]
+		keys := m.visibleFacetKeys(facet, facetData)
+		if len(keys) > 0 {
+			firstFacetKey = keys[0]
+			break
+		}
+	}
+
+	if m.activeFacet == "" && firstFacetKey != "" {
+		m.activeFacet = firstFacetKey
+	}
+
+	// With global color scaling every column shares the largest bucket count
+	globalMaxBucketCount := 0
+	if m.globalColorScale {
+		for _, facet := range facets {
+			for _, key := range m.visibleFacetKeys(facet, dataSource[facet]) {
+				for _, count := range bins.Counts(dataSource[facet][key]) {
+					globalMaxBucketCount = max(globalMaxBucketCount, count)
+				}
+			}
+		}
+	}
+
+	// With -overflow, out-of-range counts get a field on each side of the
+	// histograms, wide enough for the largest count
+	overflowWidth := 0
+	if m.showOverflow {
+		most := 0
+		for _, facet := range facets {
+			for _, values := range dataSource[facet] {
+				under, over := bins.Outside(values)
+				most = max(most, max(under, over))
+			}
+		}
+		overflowWidth = len(strconv.Itoa(most)) + 2 // marker, count and a space
+	}
+
+	// The distinct-count column has no per-key data, just a summary line
+	if m.distinctColumn > 0 && (m.distinct != nil || m.filteredDistinct != nil) {
+		facets = append(facets, m.distinctColumn)
+		sort.Ints(facets)
+	}
+
+	// line counts the content lines written so far, for mouse hit-testing
+	line := 0
+	for _, facet := range facets {
+		if facet == m.distinctColumn {
+			output.WriteString(m.distinctSummary() + "\n\n")
+			line += 2
+			continue
+		}
+		facetData := dataSource[facet]
+
+		// Build a slice of keys and sort them by descending mean
+		keys := m.visibleFacetKeys(facet, facetData)
+
+		// Skip columns with no keys matching the search
+		if len(keys) == 0 && m.searchQuery != "" {
+			continue
+		}
+
+		// Column cardinality, sample count and entropy give context for the
+		// rows below
+		samples := 0
+		for _, values := range facetData {
+			samples += len(values)
+		}
+		entropy, evenness := keyEntropy(facetData)
+		name := fmt.Sprintf("Facet %d", facet)
+		if facet == m.crossColumn {
+			name += fmt.Sprintf(" (%d×%d)", m.cross[0], m.cross[1])
+		}
+		output.WriteString(fmt.Sprintf("%s: %d keys, %d samples, entropy %s bits (%s of max)\n",
+			name, len(facetData), samples, formatFloat(entropy, m.precision, 2), formatFloat(evenness, m.precision, 2)))
+
+		// Pad keys to this column's longest shown key, so one long key
+		// doesn't indent every other column; the scale row above the
+		// cells takes the same padding
+		maxKeyLength := 10
+		for _, key := range keys {
+			// Add extra width for the pin/exclude marker if this key has one
+			_, markerWidth := m.keyMarker(key)
+			maxKeyLength = max(maxKeyLength, runewidth.StringWidth(key)+markerWidth)
+		}
+
+		// Add to active facet keys for navigation
+		m.activeFacetKeys = append(m.activeFacetKeys, keys...)
+
+		// Calculate max count across all buckets for color normalization
+		maxBucketCount := globalMaxBucketCount
+		if !m.globalColorScale {
+			for _, key := range keys {
+				for _, count := range bins.Counts(facetData[key]) {
+					maxBucketCount = max(maxBucketCount, count)
+				}
+			}
+		}
+
+		// Every row's histogram is histWidth wide, so its stats start at
+		// statsColumn: after the lead, key, gap, histogram and a space
+		histWidth := bucketCount * cellWidth
+		statsColumn := 2 + maxKeyLength + 2 + histWidth + 1 + 2*overflowWidth
+
+		line++ // column header
+
+		// Show bucket scale at the top, unless only the stats are shown
+		if !m.stats {
+			output.WriteString("  ")
+			output.WriteString(strings.Repeat(" ", maxKeyLength))
+			output.WriteString("  ")
+			output.WriteString(strings.Repeat(" ", overflowWidth))
+
+			scientific := scientificRange(gmin, gmax)
+			if m.compact {
+				// Sparklines are one cell per bucket, so only label the ends
+				output.WriteString(fmt.Sprintf("%-*s%s\n", bucketCount, formatAxis(gmin, scientific, m.precision, 1), formatAxis(gmax, scientific, m.precision, 1)))
+			} else {
+				// Label a bucket's edge every 25 columns or so, and the end of
+				// the range; a label too long for its slot pushes the next
+				// one along
+				var scale strings.Builder
+				every := max(1, 25/cellWidth)
+				for i := 0; i <= bucketCount; i += every {
+					edge := gmax
+					if i < bucketCount {
+						edge = bins.Edge(i)
+					}
+					if pad := i*cellWidth - scale.Len(); pad > 0 {
+						scale.WriteString(strings.Repeat(" ", pad))
+					} else if i > 0 {
+						scale.WriteString(" ")
+					}
+					scale.WriteString(formatAxis(edge, scientific, m.precision, 1))
+				}
+				if bucketCount%every != 0 {
+					scale.WriteString(strings.Repeat(" ", max(1, bucketCount*cellWidth-scale.Len())))
+					scale.WriteString(formatAxis(gmax, scientific, m.precision, 1))
+				}
+				output.WriteString(scale.String() + "\n")
+			}
+			line++
+		}
+
+		// Display colorized histograms for each key
+		for _, key := range keys {
+			values := facetData[key]
+			mean, stdev := m.keyMeanStdev(facet, key, values)
+
+			// Distribute values into buckets
+			buckets := bins.Counts(values)
+
+			scaleCount := m.cellScale(maxBucketCount, buckets)
+
+			// Format stats
+			rate := m.keyRate(facet, key)
+			meanText, stdevText := formatFloat(mean, m.precision, 2), formatFloat(stdev, m.precision, 2)
+			if m.confidence {
+				meanText += " " + m.formatConfidence(stdev, len(values))
+			}
+			stats := fmt.Sprintf("μ=%s σ=%s n=%d %.1f/s", meanText, stdevText, len(values), rate)
+			if m.ascii {
+				stats = fmt.Sprintf("mean=%s sd=%s n=%d %.1f/s", meanText, stdevText, len(values), rate)
+			}
+			if m.geomean {
+				stats += " gmean=" + m.formatGeomean(values)
+			}
+			if m.trim > 0 {
+				stats += fmt.Sprintf(" tmean%s=%s", m.trimPercent(), formatFloat(m.keyTrimmedMean(values), m.precision, 2))
+			}
+			// Outlier bins get a ! after their cell
+			var outliers []bool
+			if m.outliers {
+				var count int
+				outliers, count = outlierBins(m.keyStats(values), bins)
+				stats += fmt.Sprintf(" out=%d", count)
+			}
+
+			// Store position for navigation before styling
+			// Use flat 2D layout - each key gets its own row in this facet
+			m.facetPositions[key] = [2]int{rowPosition, 0}
+
+			// Update active facet position if this is our active facet
+			if key == m.activeFacet {
+				m.activeFacetPos = [2]int{rowPosition, 0}
+			}
+
+			rowPosition++
+
+			// Output the key name with proper padding
+			keyStyle := lipgloss.NewStyle()
+
+			// Different styling based on active/pinned status
+			// The marker's display width is taken out of the padding
+			marker, markerWidth := m.keyMarker(key)
+			keyText := marker + runewidth.FillRight(key, maxKeyLength-markerWidth)
+			if m.excludedFacets[key] {
+				keyStyle = keyStyle.Strikethrough(true)
+			}
+			if key == m.activeFacet && m.pinnedFacets[key] {
+				// Both active and pinned
+				keyStyle = keyStyle.Foreground(lipgloss.Color("205")).Bold(true).Background(lipgloss.Color("23"))
+			} else if key == m.activeFacet {
+				// Just active
+				keyStyle = keyStyle.Foreground(lipgloss.Color("15")).Bold(true).Background(lipgloss.Color("27"))
+			} else if m.pinnedFacets[key] {
+				// Just pinned
+				keyStyle = keyStyle.Foreground(lipgloss.Color("205"))
+			} else if m.excludedFacets[key] || (m.minCount > 0 && m.sparse(key, values)) {
+				// Excluded keys, and those under -min-count, are dimmed
+				keyStyle = keyStyle.Foreground(lipgloss.Color("240"))
+			}
+
+			// Without color the active key is marked with a leading '>' instead
+			lead := "  "
+			if m.noColor {
+				keyStyle = lipgloss.NewStyle()
+				lead = m.activeLead(key == m.activeFacet)
+			}
+			formattedKey := keyStyle.Render(keyText)
+
+			output.WriteString(lead + formattedKey)
+			m.layout.record(key, line, line+1, 0, m.winWidth)
+			line++
+
+			output.WriteString("  ")
+
+			// Excluded keys have no data left to plot
+			if m.excludedFacets[key] {
+				output.WriteString("excluded\n")
+				continue
+			}
+
+			// Stats mode: the numbers alone, right after the key
+			if m.stats {
+				output.WriteString(m.fitStats(stats, 4+maxKeyLength) + "\n")
+				continue
+			}
+
+			under, over := bins.Outside(values)
+			underField, overField := overflowFields(under, over, overflowWidth)
+
+			// Compact mode: a sparkline followed by the headline stats
+			if m.compact {
+				output.WriteString(underField + sparkline(buckets, m.ascii) + overField)
+				compactStats := fmt.Sprintf("μ=%s n=%d", meanText, len(values))
+				if m.ascii {
+					compactStats = fmt.Sprintf("mean=%s n=%d", meanText, len(values))
+				}
+				output.WriteString(" " + m.fitStats(compactStats, 4+maxKeyLength+bucketCount+1+2*overflowWidth) + "\n")
+				continue
+			}
+
+			// Output histogram with colored squares
+			var row strings.Builder
+			blank := strings.Repeat(" ", cellWidth-1)
+			markedBin := m.percentileBin(values, bins)
+			for i, count := range buckets {
+				gap := blank
+				flagged := outliers != nil && outliers[i]
+				if flagged {
+					gap = "!" + blank[1:]
+				}
+				// The -mark-percentile bin shows the marker glyph in its cell
+				cell := " "
+				if i == markedBin {
+					cell = m.percentileGlyph()
+				}
+				// Calculate color intensity based on logarithmic scale of count
+				if count == 0 {
+					if i != markedBin {
+						cell = m.emptyCell
+					}
+					row.WriteString(cell + blank)
+				} else {
+					normalized := m.cellIntensity(count, scaleCount)
+
+					// Without color, intensity is shown by glyph density instead
+					if ramp := m.intensityRamp(); ramp != nil {
+						if i != markedBin {
+							level := 1 + int(normalized*float64(len(ramp)-2))
+							cell = ramp[min(level, len(ramp)-1)]
+						}
+						row.WriteString(cell + gap)
+						continue
+					}
+
+					// Map to the palette's color ramp (low to high)
+					color := m.palette.color(normalized)
+
+					square := lipgloss.NewStyle().
+						Background(lipgloss.Color(fmt.Sprintf("%d", color))).
+						Foreground(lipgloss.Color("15")).
+						Render(cell)
+
+					if !m.noColor && flagged {
+						gap = outlierStyle.Render("!") + blank[1:]
+					}
+					row.WriteString(square + gap)
+				}
+			}
+
+			// Pad the histogram to its full width so the stats form a column
+			cells := row.String()
+			output.WriteString(underField + cells + strings.Repeat(" ", max(0, histWidth-lipgloss.Width(cells))) + overField)
+			output.WriteString(" " + m.fitStats(stats, statsColumn) + "\n")
+		}
+		output.WriteString("\n")
+		line++
+	}
+	if m.markPercentile > 0 && !m.stats && !m.compact {
+		output.WriteString(fmt.Sprintf("%s marks the bin holding each key's %s\n", m.percentileGlyph(), m.percentileLabel()))
+	}
+	return output.String()
+}
+
+// percentileBin returns the bin of b holding the -mark-percentile percentile
+// of values, or -1 if no percentile is marked.
+func (m model) percentileBin(values []float64, b histogram.Binning) int {
+	if m.markPercentile <= 0 || len(values) == 0 {
+		return -1
+	}
+	return b.Index(m.keyStats(values).Percentile(m.markPercentile))
+}
+
+// percentileGlyph returns the glyph marking the -mark-percentile bin.
+func (m model) percentileGlyph() string {
+	if m.ascii {
+		return "|"
+	}
+	return "┃"
+}
+
+// percentileLabel names the -mark-percentile percentile, like p99 or p99.9.
+func (m model) percentileLabel() string {
+	return percentileName(m.markPercentile)
+}
+
+// percentileName names a percentile, like p99 or p99.9.
+func percentileName(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'g', -1, 64)
+}
+
+// parsePercentiles parses a -percentiles list, like 50,90,99.
+func parsePercentiles(s string) ([]float64, error) {
+	var percentiles []float64
+	for _, field := range strings.Split(s, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || p < 0 || p > 100 {
+			return nil, fmt.Errorf("-percentiles must be numbers from 0 to 100, got %q", field)
+		}
+		percentiles = append(percentiles, p)
+	}
+	return percentiles, nil
+}
+
+// percentileList formats percentiles as a -percentiles list.
+func percentileList(percentiles []float64) string {
+	fields := make([]string, len(percentiles))
+	for i, p := range percentiles {
+		fields[i] = strconv.FormatFloat(p, 'g', -1, 64)
+	}
+	return strings.Join(fields, ",")
+}
+
+// overflowFields returns the fields drawn before and after an all-facets
+// histogram row with the counts of values below and above its range. Both
+// are width wide, and empty when width is 0.
+func overflowFields(under, over, width int) (string, string) {
+	if width == 0 {
+		return "", ""
+	}
+	left, right := "", ""
+	if under > 0 {
+		left = fmt.Sprintf("<%d ", under)
+	}
+	if over > 0 {
+		right = fmt.Sprintf(" >%d", over)
+	}
+	return fmt.Sprintf("%*s", width, left), fmt.Sprintf("%-*s", width, right)
+}
+
+// renderWidth is the width the views are laid out for: the window width,
+// capped by -width.
+func (m model) renderWidth() int {
+	if m.maxWidth > 0 {
+		return min(m.winWidth, m.maxWidth)
+	}
+	return m.winWidth
+}
+
+// panelSubtitle returns the count and mean shown under a single-facet panel's
+// title, truncated to width cells.
+func (m model) panelSubtitle(count int, mean float64, width int) string {
+	tail := "…"
+	if m.ascii {
+		tail = "..."
+	}
+	subtitle := fmt.Sprintf("n=%d  mean %s", count, formatFloat(mean, m.precision, 2))
+	return runewidth.Truncate(subtitle, width, tail)
+}
+
+// fitStats truncates the stats at the end of a row that start at column
+// start, so the row doesn't overflow the window.
+func (m model) fitStats(stats string, start int) string {
+	tail := "…"
+	if m.ascii {
+		tail = "..."
+	}
+	width := m.renderWidth() - start
+	if width < runewidth.StringWidth(tail) {
+		return ""
+	}
+	return runewidth.Truncate(stats, width, tail)
+}
+
+// sparkline renders bucket counts as a single row of eighth-block glyphs (or
+// ASCII intensity glyphs), scaled to the largest bucket. Non-empty buckets are
+// always visible.
+func sparkline(counts []int, ascii bool) string {
+	glyphs := eighthBlocks
+	if ascii {
+		glyphs = asciiRamp
+	}
+
+	maxCount := 0
+	for _, count := range counts {
+		maxCount = max(maxCount, count)
+	}
+
+	var builder strings.Builder
+	levels := len(glyphs) - 1
+	for _, count := range counts {
+		if count == 0 || maxCount == 0 {
+			builder.WriteString(" ")
+			continue
+		}
+		level := max(1, int(math.Round(float64(count)/float64(maxCount)*float64(levels))))
+		builder.WriteString(glyphs[level])
+	}
+	return builder.String()
+}
+
+// palette selects the terminal color ramp used for bucket intensities.
+type palette int
+
+const (
+	paletteSpectrum palette = iota // blue → green → yellow → red
+	paletteViridis                 // perceptually uniform purple → teal → yellow
+	paletteCividis                 // blue → gray → yellow, safe for red-green colorblindness
+)
+
+// paletteRamps holds each palette's 256-color codes from low to high, in
+// groups that are each given an equal share of the intensity range.
+var paletteRamps = map[palette][][]int{
+	paletteSpectrum: {
+		{27, 28, 29, 30, 31, 32, 33},        // blue
+		{40, 41, 42, 43, 44, 45, 46},        // green
+		{202, 203, 204, 205, 206, 207, 208}, // orange-yellow
+		{196, 197, 198, 199, 200, 201},      // red-orange
+	},
+	paletteViridis: {
+		{53, 54, 60, 61},
+		{67, 31, 30, 37},
+		{36, 35, 71, 77},
+		{113, 149, 185, 226},
+	},
+	paletteCividis: {
+		{17, 18, 24, 60},
+		{66, 102, 138, 144},
+		{180, 186, 221, 226},
+	},
+}
+
+// keyColorCount is the number of colors each palette offers for telling keys
+// apart, matching the stacked view's glyphs for when color is disabled.
+const keyColorCount = 8
+
+// paletteKeyColors holds each palette's colors for keys in the stacked and
+// compare views: picks from across the palette's range, spaced so no two are
+// hard to tell apart.
+var paletteKeyColors = map[palette][keyColorCount]string{
+	paletteSpectrum: {"39", "205", "214", "76", "141", "203", "45", "227"},
+	paletteViridis:  {"54", "61", "31", "36", "71", "113", "185", "226"},
+	paletteCividis:  {"17", "24", "60", "102", "138", "144", "186", "226"},
+}
+
+// keySlot returns the key color slot of a key, from a hash of its name, so
+// it is the same from one frame, and one run, to the next.
+func keySlot(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % keyColorCount)
+}
+
+// keyColor returns the color in a key color slot of the active palette.
+func (m model) keyColor(slot int) lipgloss.Color {
+	return lipgloss.Color(paletteKeyColors[m.palette][slot])
+}
+
+// colorForKey returns the color of a key in the active palette.
+func (m model) colorForKey(key string) lipgloss.Color {
+	return m.keyColor(keySlot(key))
+}
+
+// parsePalette converts a -palette flag value into a palette.
+func parsePalette(s string) (palette, error) {
+	switch s {
+	case "spectrum":
+		return paletteSpectrum, nil
+	case "viridis":
+		return paletteViridis, nil
+	case "cividis":
+		return paletteCividis, nil
+	}
+	return paletteSpectrum, fmt.Errorf("unknown palette %q (want spectrum, viridis, or cividis)", s)
+}
+
+// String returns the -palette flag value for the palette.
+func (p palette) String() string {
+	switch p {
+	case paletteViridis:
+		return "viridis"
+	case paletteCividis:
+		return "cividis"
+	}
+	return "spectrum"
+}
+
+// color maps a normalized intensity in [0, 1] to a terminal color code.
+func (p palette) color(normalized float64) int {
+	groups := paletteRamps[p]
+	pos := math.Min(math.Max(normalized, 0), 1) * float64(len(groups))
+	g := min(int(pos), len(groups)-1)
+	group := groups[g]
+	return group[min(int((pos-float64(g))*float64(len(group))), len(group)-1)]
+}
+
+// renderColorGradient displays the color gradient used in the visualization
+// (or the glyph ramp that replaces it when color is disabled).
+func renderColorGradient(ramp []string, p palette, reverse bool) string {
+	var builder strings.Builder
+
+	// Without color the legend shows the glyph ramp that replaces the colors
+	if ramp != nil {
+		glyphs := append([]string(nil), ramp[1:]...)
+		if reverse {
+			reverseStrings(glyphs)
+		}
+		builder.WriteString("low ")
+		builder.WriteString(strings.Join(glyphs, ""))
+		builder.WriteString(" high")
+		return builder.String()
+	}
+
+	// Display each color in the gradient with spacing between groups; the
+	// legend runs from low to high counts, so reversed colors run backwards
+	groups := paletteRamps[p]
+	for g := range groups {
+		group := groups[g]
+		if reverse {
+			group = groups[len(groups)-1-g]
+		}
+		for c := range group {
+			color := group[c]
+			if reverse {
+				color = group[len(group)-1-c]
+			}
+			square := lipgloss.NewStyle().
+				Background(lipgloss.Color(fmt.Sprintf("%d", color))).
+				Render("  ")
+			builder.WriteString(square)
+		}
+		builder.WriteString(" ")
+	}
+
+	return builder.String()
+}
+
+// reverseStrings reverses s in place.
+func reverseStrings(s []string) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// View renders the complete UI, including scrolling the content.
+func (m model) View() string {
+	// Panels and histograms can't be laid out sensibly below a minimum size;
+	// WindowSizeMsg updates the size, so this recovers on the next resize
+	if m.winWidth < minWindowWidth || m.winHeight < minWindowHeight {
+		return fmt.Sprintf("Terminal too small (%dx%d).\nResize to at least %dx%d.",
+			m.winWidth, m.winHeight, minWindowWidth, minWindowHeight)
+	}
+
+	content := m.renderContent()
+
+	// Combine header/instructions and content.
+	// We'll apply scrolling only to the content portion.
+	staticPart := m.renderStatic()
+
+	// Split content into lines.
+	contentLines := strings.Split(content, "\n")
+	// Calculate available height for content.
+	availableHeight := m.availableContentHeight(staticPart)
+	// Clamp scroll offset.
+	maxScroll := len(contentLines) - availableHeight
+	if maxScroll < 0 {
+		maxScroll = 0
+	}
+	if m.scrollOffset > maxScroll {
+		m.scrollOffset = maxScroll
+	}
+	if m.layout != nil {
+		m.layout.contentTop = lipgloss.Height(staticPart) - 1
+		m.layout.scroll = m.scrollOffset
+	}
+	// Extract the visible portion.
+	visible := contentLines[m.scrollOffset:min(m.scrollOffset+availableHeight, len(contentLines))]
+
+	// Pin the footer to the bottom of the window
+	if footer := m.renderFooter(); footer != "" {
+		for len(visible) < availableHeight {
+			visible = append(visible, "")
+		}
+		visible = append(visible, footer)
+	}
+	return staticPart + strings.Join(visible, "\n")
+}
+
+// action is something a key can be bound to in the keymap.
+type action int
+
+const (
+	actionQuit action = iota
+	actionSearch
+	actionSavePins
+	actionClear
+	actionPause
+	actionFirst
+	actionLast
+	actionSort
+	actionReverse
+	actionHelp
+	actionBack
+	actionPrevFacet
+	actionNextFacet
+	actionAllFacets
+	actionToggleScale
+	actionToggleColorScale
+	actionDensity
+	actionBoxPlot
+	actionStacked
+	actionHeatmap
+	actionLeft
+	actionRight
+	actionUp
+	actionDown
+	actionScrollUp
+	actionScrollDown
+	actionPageUp
+	actionPageDown
+	actionPin
+	actionCompare
+	actionExclude
+	actionOutliers
+	actionExport
+	actionDiff
+	actionHorizontal
+	actionFacet1 // actionFacet1 through actionFacet9 jump to that facet column
+	actionFacet2
+	actionFacet3
+	actionFacet4
+	actionFacet5
+	actionFacet6
+	actionFacet7
+	actionFacet8
+	actionFacet9
+	actionExportSVG
+	actionResort
+	actionLegend
+	actionStats
+	actionExtremes
+	actionSparse
+	actionCount // number of actions; not an action
+)
+
+// actionNames are the names actions are rebound by in the config file,
+// indexed by action.
+var actionNames = []string{
+	"quit", "search", "save-pins", "clear", "pause", "first", "last", "sort",
+	"reverse", "help", "back", "prev-facet", "next-facet", "all-facets",
+	"toggle-scale", "toggle-color-scale", "density", "boxplot", "stacked",
+	"heatmap", "left", "right", "up", "down", "scroll-up", "scroll-down",
+	"page-up", "page-down", "pin", "compare", "exclude", "outliers", "export",
+	"diff", "horizontal", "facet-1", "facet-2", "facet-3", "facet-4", "facet-5",
+	"facet-6", "facet-7", "facet-8", "facet-9", "export-svg", "resort", "legend", "stats", "extremes", "sparse",
+}
+
+func (a action) String() string {
+	return actionNames[a]
+}
+
+// defaultKeys are the built-in bindings, as reported by tea.KeyMsg.String.
+var defaultKeys = map[action][]string{
+	actionQuit:             {"q"},
+	actionSearch:           {"/"},
+	actionSavePins:         {"w"},
+	actionClear:            {"c"},
+	actionPause:            {" "},
+	actionFirst:            {"g"},
+	actionLast:             {"G"},
+	actionSort:             {"s"},
+	actionReverse:          {"r"},
+	actionHelp:             {"?"},
+	actionBack:             {"esc"},
+	actionPrevFacet:        {"a"},
+	actionNextFacet:        {"d"},
+	actionAllFacets:        {"0"},
+	actionToggleScale:      {"x"},
+	actionToggleColorScale: {"X"},
+	actionDensity:          {"n"},
+	actionBoxPlot:          {"b"},
+	actionStacked:          {"t"},
+	actionHeatmap:          {"h"},
+	actionLeft:             {"left"},
+	actionRight:            {"right"},
+	actionUp:               {"up"},
+	actionDown:             {"down"},
+	actionScrollUp:         {"k"},
+	actionScrollDown:       {"j"},
+	actionPageUp:           {"pgup", "ctrl+b"},
+	actionPageDown:         {"pgdown", "ctrl+f"},
+	actionPin:              {"enter"},
+	actionCompare:          {"m"},
+	actionExclude:          {"-"},
+	actionOutliers:         {"o"},
+	actionExport:           {"e"},
+	actionDiff:             {"D"},
+	actionHorizontal:       {"H"},
+	actionFacet1:           {"1"},
+	actionFacet2:           {"2"},
+	actionFacet3:           {"3"},
+	actionFacet4:           {"4"},
+	actionFacet5:           {"5"},
+	actionFacet6:           {"6"},
+	actionFacet7:           {"7"},
+	actionFacet8:           {"8"},
+	actionFacet9:           {"9"},
+	actionExportSVG:        {"S"},
+	actionResort:           {"R"},
+	actionLegend:           {"L"},
+	actionStats:            {"v"},
+	actionExtremes:         {"M"},
+	actionSparse:           {"N"},
+}
+
+// keymap binds keys to actions; Update looks keys up here rather than
+// matching them directly, so they can be rebound.
+type keymap struct {
+	keys  map[action][]string
+	byKey map[string]action
+}
+
+// newKeymap returns the default bindings with overrides, keyed by action
+// name, applied. An override replaces all of the action's default keys; a
+// key bound to two actions is an error.
+func newKeymap(overrides map[string][]string) (*keymap, error) {
+	km := &keymap{keys: make(map[action][]string), byKey: make(map[string]action)}
+	for a, keys := range defaultKeys {
+		km.keys[a] = keys
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a, ok := parseAction(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown key action %q", name)
+		}
+		var keys []string
+		for _, key := range overrides[name] {
+			if key == "space" {
+				key = " "
+			}
+			keys = append(keys, key)
+		}
+		km.keys[a] = keys
+	}
+
+	for a := action(0); a < actionCount; a++ {
+		for _, key := range km.keys[a] {
+			if key == "ctrl+c" {
+				return nil, fmt.Errorf("key %s is reserved for quitting", keyLabel(key))
+			}
+			if other, ok := km.byKey[key]; ok {
+				return nil, fmt.Errorf("key %s is bound to both %s and %s", keyLabel(key), other, a)
+			}
+			km.byKey[key] = a
+		}
+	}
+	return km, nil
+}
+
+// parseAction looks up an action by its config name.
+func parseAction(name string) (action, bool) {
+	for a, n := range actionNames {
+		if n == name {
+			return action(a), true
+		}
+	}
+	return 0, false
+}
+
+// defaultKeymap is used when the model wasn't given one.
+var defaultKeymap, _ = newKeymap(nil)
+
+// keymap returns the active key bindings.
+func (m model) keymap() *keymap {
+	if m.keys != nil {
+		return m.keys
+	}
+	return defaultKeymap
+}
+
+// keyLabels are the display names of keys that don't print as themselves.
+var keyLabels = map[string]string{
+	" ": "Space", "enter": "Enter", "esc": "Esc", "tab": "Tab", "backspace": "Backspace",
+	"left": "←", "right": "→", "up": "↑", "down": "↓", "pgup": "PgUp", "pgdown": "PgDn",
+}
+
+// keyLabel returns a key as shown to the user.
+func keyLabel(key string) string {
+	if label, ok := keyLabels[key]; ok {
+		return label
+	}
+	if strings.HasPrefix(key, "ctrl+") {
+		return "Ctrl+" + strings.ToUpper(strings.TrimPrefix(key, "ctrl+"))
+	}
+	return key
+}
+
+// label shows the keys bound to actions, separated by slashes; arrow keys
+// run together as "←→↑↓". With all false, only each action's first key is
+// shown.
+func (km *keymap) label(actions []action, all bool) string {
+	var labels []string
+	arrows := true
+	for _, a := range actions {
+		keys := km.keys[a]
+		if !all && len(keys) > 1 {
+			keys = keys[:1]
+		}
+		for _, key := range keys {
+			label := keyLabel(key)
+			arrows = arrows && strings.Contains("←→↑↓", label)
+			labels = append(labels, label)
+		}
+	}
+	if arrows {
+		return strings.Join(labels, "")
+	}
+	return strings.Join(labels, "/")
+}
+
+// keyBinding documents a key (or group of keys) handled in Update.
+type keyBinding struct {
+	actions []action // whose keys are shown
+	keys    string   // shown instead when there are no actions
+	short   string   // label in the instructions line; empty to leave it out
+	help    string   // description in the help overlay
+}
+
+// keyBindings is the single list of keys that drives both the instructions
+// line and the help overlay; add new actions here when adding them to Update.
+var keyBindings = []keyBinding{
+	{[]action{actionPrevFacet, actionNextFacet}, "", "Change Facet", "Show the previous/next facet column"},
+	{[]action{actionLeft, actionRight, actionUp, actionDown}, "", "Navigate", "Move between facets"},
+	{[]action{actionPin}, "", "Pin", "Pin/unpin the selected facet: only rows matching every pin are shown"},
+	{[]action{actionExclude}, "", "Exclude", "Exclude/un-exclude the selected facet: matching rows are dropped"},
+	{[]action{actionAllFacets}, "", "All Facets", "Show all facet columns"},
+	{[]action{actionFacet1, actionFacet2, actionFacet3, actionFacet4, actionFacet5, actionFacet6, actionFacet7, actionFacet8, actionFacet9}, "", "", "Jump to that facet column"},
+	{[]action{actionToggleScale, actionToggleColorScale}, "", "Scale/Color Scale", "Toggle per-facet axis scaling / global color normalization"},
+	{[]action{actionCompare}, "", "Compare", "Mark a facet; again on a second one overlays them, again to leave"},
+	{[]action{actionDensity}, "", "Density", "Toggle density (relative-frequency) normalization"},
+	{[]action{actionBoxPlot}, "", "Box Plot", "Toggle box plots in the single-facet view"},
+	{[]action{actionHorizontal}, "", "Horizontal", "Toggle horizontal bars (one labeled row per bin) in the single-facet view"},
+	{[]action{actionLegend}, "", "", "Show/hide the color legend under the all-facets view"},
+	{[]action{actionStats}, "", "Stats", "Toggle between histograms and mean/stdev/count stats"},
+	{[]action{actionSparse}, "", "", "Show (dimmed)/hide the keys with fewer values than -min-count"},
+	{[]action{actionExtremes}, "", "", "Show/hide the global max and min, and the keys they came from, in the header"},
+	{[]action{actionOutliers}, "", "Outliers", "Toggle highlighting of bins holding outliers (beyond 1.5 IQR from the quartiles)"},
+	{[]action{actionStacked, actionHeatmap}, "", "Stacked/Heatmap", "Toggle the stacked histogram / key × bin heatmap of a facet column"},
+	{[]action{actionPause}, "", "Pause", "Pause/resume; input is buffered while paused and replayed on resume"},
+	{[]action{actionClear}, "", "Clear", "Clear all accumulated data (keeps the view and pins)"},
+	{[]action{actionSavePins}, "", "Save Pins", "Save the current pins to the -pins-file"},
+	{[]action{actionExport}, "", "Export", "Write a JSON snapshot of every key's statistics to the -export-json file"},
+	{[]action{actionExportSVG}, "", "", "Write the current view as an SVG image to the -export-svg file"},
+	{[]action{actionDiff}, "", "Diff", "Toggle a diff of every key's statistics against the -baseline snapshot"},
+	{[]action{actionFirst, actionLast}, "", "First/Last", "Jump to the first/last facet"},
+	{[]action{actionSort, actionReverse}, "", "Sort/Reverse", "Cycle the facet sort order (mean, count, name, stdev, numeric) / reverse it"},
+	{[]action{actionResort}, "", "", "Re-sort the keys once with -stable-order, then hold the new order"},
+	{[]action{actionSearch}, "", "Search", "Filter facet keys by substring; Enter keeps the filter, Esc clears it"},
+	{[]action{actionScrollDown, actionScrollUp, actionPageUp, actionPageDown}, "", "Scroll", "Scroll the content a line/page at a time"},
+	{nil, "Mouse", "", "Click a facet to select it; click again or right-click to pin; wheel scrolls"},
+	{[]action{actionHelp}, "", "Help", "Show/hide this help"},
+	{[]action{actionBack}, "", "", "Close the help, or clear the search filter"},
+	{[]action{actionQuit}, "", "Quit", "Quit (Ctrl+C always quits)"},
+}
+
+// bindingKeys returns the keys shown for a binding; all is as for
+// keymap.label.
+func (m model) bindingKeys(binding keyBinding, all bool) string {
+	if len(binding.actions) == 0 {
+		return binding.keys
+	}
+	return m.keymap().label(binding.actions, all)
+}
+
+// renderHelp lists every key binding and the current settings.
+func (m model) renderHelp() string {
+	var b strings.Builder
+	b.WriteString("Keys\n\n")
+	width := 0
+	for _, binding := range keyBindings {
+		width = max(width, runewidth.StringWidth(m.bindingKeys(binding, true)))
+	}
+	for _, binding := range keyBindings {
+		b.WriteString("  " + runewidth.FillRight(m.bindingKeys(binding, true), width) + "  " + binding.help + "\n")
+	}
+
+	bins := "default"
+	if m.autoBins {
+		bins = "auto"
+	} else if m.binCount > 0 {
+		bins = strconv.Itoa(m.binCount)
+	}
+	if m.equalFreq {
+		bins = "equalfreq"
+		if m.binCount > 0 {
+			bins += ":" + strconv.Itoa(m.binCount)
+		}
+	}
+	stdevConvention := "false (population, divides by n)"
+	if m.sampleStdev {
+		stdevConvention = "true (sample, divides by n-1)"
+	}
+	settings := []struct{ name, value string }{
+		{"-facet", strconv.Itoa(m.facet)},
+		{"-stats", strconv.FormatBool(m.stats)},
+		{"-height", strconv.Itoa(m.barHeight)},
+		{"-log", strconv.FormatBool(m.logScale)},
+		{"-bars", m.bars.String()},
+		{"-bins", bins},
+		{"-per-facet-scale", strconv.FormatBool(m.perFacetScale)},
+		{"-global-color", strconv.FormatBool(m.globalColorScale)},
+		{"-density", strconv.FormatBool(m.density)},
+		{"-ewma", strconv.FormatFloat(m.ewmaAlpha, 'g', -1, 64)},
+		{"-overflow", strconv.FormatBool(m.showOverflow)},
+		{"-outliers", strconv.FormatBool(m.outliers)},
+		{"-zero", strconv.FormatBool(m.zeroBaseline)},
+		{"-scale", m.valueScale.spec},
+		{"-mark-percentile", strconv.FormatFloat(m.markPercentile, 'g', -1, 64)},
+		{"-extremes", strconv.FormatBool(m.showExtremes)},
+		{"-min-count", strconv.Itoa(m.minCount)},
+		{"-empty-cell", m.emptyCell},
+		{"-tight-cells", strconv.FormatBool(m.tightCells)},
+		{"-trim", strconv.FormatFloat(m.trim, 'g', -1, 64)},
+		{"-geomean", strconv.FormatBool(m.geomean)},
+		{"-ci", strconv.FormatBool(m.confidence)},
+		{"-sample-stdev", stdevConvention},
+		{"-distinct", strconv.Itoa(m.distinctColumn)},
+		{"-ignore-cols", ignoredColumnList(m.ignoredColumns)},
+		{"-time-col", strconv.Itoa(m.timeColumn)},
+		{"-cross", crossList(m.cross)},
+		{"-cross-max", strconv.Itoa(m.crossMax)},
+		{"-time-layout", m.timeLayout},
+		{"-time-bucket", m.timeBucket.String()},
+		{"-na", naTokenList(m.naTokens)},
+		{"-y-axis", strconv.FormatBool(m.yAxis)},
+		{"-marker", m.marker.String()},
+		{"-compact", strconv.FormatBool(m.compact)},
+		{"-boxplot", strconv.FormatBool(m.boxPlot)},
+		{"-horizontal", strconv.FormatBool(m.horizontal)},
+		{"-ascii", strconv.FormatBool(m.ascii)},
+		{"-no-color", strconv.FormatBool(m.noColor)},
+		{"-palette", m.palette.String()},
+		{"-reverse-colors", strconv.FormatBool(m.reverseColors)},
+		{"-no-legend", strconv.FormatBool(m.hideLegend)},
+		{"-sort", m.sortMode.String()},
+		{"-stable-order", strconv.FormatBool(m.stableOrder != nil)},
+		{"-pin-marker", m.markers.pin},
+		{"-exclude-marker", m.markers.exclude},
+		{"-active-marker", m.markers.active},
+		{"-precision", strconv.Itoa(m.precision)},
+		{"-window", m.window.String()},
+		{"-header", strconv.FormatBool(m.header)},
+		{"-delimiter", strconv.Quote(m.delimiter)},
+		{"-value-col", strconv.Itoa(m.valueColumn)},
+		{"-percentiles", percentileList(m.percentiles)},
+		{"-max-lines", strconv.Itoa(m.maxLines)},
+		{"-top", strconv.Itoa(m.topStrings)},
+		{"-width", strconv.Itoa(m.maxWidth)},
+		{"-pins-file", m.pinsFile},
+		{"-export-json", m.exportPath},
+		{"-export-svg", m.svgPath},
+	}
+	b.WriteString("\nSettings\n\n")
+	for _, s := range settings {
+		b.WriteString(fmt.Sprintf("  %-16s %s\n", s.name, s.value))
+	}
+
+	help := b.String()
+	if m.ascii {
+		help = strings.Replace(help, "←→↑↓", "Arrows", 1)
+		help = strings.Replace(help, "×", "x", -1)
+	}
+	return help
+}
+
+// renderStatic renders the non-scrolling header and instructions.
+func (m model) renderStatic() string {
+	header := m.renderHeader()
+
+	// Render instructions
+	var labels []string
+	for _, binding := range keyBindings {
+		if binding.short != "" {
+			labels = append(labels, m.bindingKeys(binding, false)+": "+binding.short)
+		}
+	}
+	instructions := strings.Join(labels, " | ")
+	if m.searching {
+		// The search prompt replaces the instructions while typing
+		instructions = fmt.Sprintf("Search: %s_  (Enter: keep filter | Esc: clear)", m.searchQuery)
+	}
+	if m.ascii {
+		instructions = strings.Replace(instructions, "←→↑↓", "Arrows", 1)
+	}
+	if !m.noColor {
+		instructions = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("242")).
+			Render(instructions)
+	}
+
+	return header + "\n\n" + instructions + "\n\n"
+}
+
+// renderContent renders the scrollable body for the current view.
+func (m model) renderContent() string {
+	if m.layout != nil {
+		m.layout.regions = m.layout.regions[:0]
+	}
+	// Help is available before any data arrives
+	if m.showHelp {
+		return m.renderHelp()
+	}
+	if m.totalLogCount == 0 {
+		return m.renderWaiting()
+	}
+
+	var content string
+	switch m.currentView() {
+	case viewDiff:
+		content = m.renderDiff()
+	case viewCompare:
+		content = m.renderCompare()
+	case viewStrings:
+		content = m.renderStringHistogram()
+	case viewDistinct:
+		content = m.distinctSummary()
+	case viewHeatmap:
+		content = m.renderHeatmap()
+	case viewStacked:
+		content = m.renderStacked()
+	case viewSingleFacet:
+		content = m.renderSingleFacet()
+	default:
+		content = m.renderMultiFacet()
+	}
+
+	// Numeric views are drawn in -scale units
+	if len(m.stringValues) == 0 && (m.facet == 0 || m.facet != m.distinctColumn) {
+		content += m.scaleCaption()
+	}
+
+	// Add the color gradient legend only to the multi-facet view
+	if m.facet == 0 && len(m.stringValues) == 0 && !m.compact && !m.stats && m.compareWith.key == "" && !m.showDiff && !m.hideLegend {
+		ramp := m.intensityRamp()
+		legend := renderColorGradient(ramp, m.palette, m.reverseColors)
+		if ramp == nil {
+			// The glyph ramp has its own low/high labels
+			legend = "low " + legend + "high"
+		}
+		content += "Bucket count: " + legend + "  " + m.colorScaleNote()
+	}
+	return content
+}
+
+// contentView is one of the views renderContent draws the data in.
+type contentView int
+
+const (
+	viewMultiFacet contentView = iota
+	viewSingleFacet
+	viewHeatmap
+	viewStacked
+	viewDistinct
+	viewStrings
+	viewCompare
+	viewDiff
+)
+
+// String returns the name of the view.
+func (v contentView) String() string {
+	return [...]string{"all-facets", "facet", "heatmap", "stacked", "distinct", "string", "compare", "diff"}[v]
+}
+
+// currentView returns the view the data is drawn in, from the modes set.
+func (m model) currentView() contentView {
+	switch {
+	case m.showDiff && m.baseline != nil:
+		return viewDiff
+	case m.compareWith.key != "":
+		return viewCompare
+	case len(m.stringValues) > 0:
+		return viewStrings
+	case m.facet != 0 && m.facet == m.distinctColumn:
+		return viewDistinct
+	case m.facet != 0 && m.heatmap:
+		return viewHeatmap
+	case m.facet != 0 && m.stacked:
+		return viewStacked
+	case m.facet != 0:
+		return viewSingleFacet
+	}
+	return viewMultiFacet
+}
+
+// scaleCaption notes a -scale transform under the numeric views, whose axes
+// are in transformed units.
+func (m model) scaleCaption() string {
+	if m.valueScale.spec == "" {
+		return ""
+	}
+	return "Axis: " + m.valueScale.label() + "\n"
+}
+
+// spinnerFrames animate the waiting message, one frame per tick.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// asciiSpinnerFrames replace spinnerFrames in ASCII mode.
+var asciiSpinnerFrames = []string{"|", "/", "-", "\\"}
+
+// renderWaiting explains why nothing has been counted yet, so a slow or
+// wrong producer can be told apart from a hung histo.
+func (m model) renderWaiting() string {
+	elapsed := time.Since(m.startTime)
+	since := elapsed.Truncate(time.Second).String()
+
+	switch {
+	case m.pendingLines > 0:
+		return fmt.Sprintf("Paused with %d lines buffered; press Space to resume.", m.pendingLines)
+	case len(m.storedLines) > 0:
+		return fmt.Sprintf("Received %d lines in %s, but all were blank.", len(m.storedLines), since)
+	case m.lines == nil:
+		return "Input ended without any data."
+	}
+
+	frames, ellipsis := spinnerFrames, "…"
+	if m.ascii {
+		frames, ellipsis = asciiSpinnerFrames, "..."
+	}
+	frame := frames[int(elapsed/(500*time.Millisecond))%len(frames)]
+	source := "stdin"
+	if m.inputName != "" {
+		source = m.inputName
+	} else if m.input != nil {
+		source = "input"
+	}
+	return fmt.Sprintf("%s Waiting for %s%s (%s)", frame, source, ellipsis, since)
+}
+
+// colorScaleNote explains what the all-facets colors are relative to.
+func (m model) colorScaleNote() string {
+	switch {
+	case m.density:
+		return "(color: per key, shows each key's shape)"
+	case m.globalColorScale:
+		return "(color: global, comparable across columns; sparse columns look faint)"
+	}
+	return "(color: per column, comparable within a column only)"
+}
+
+// availableContentHeight returns the number of rows left for content below staticPart.
+func (m model) availableContentHeight(staticPart string) int {
+	height := m.winHeight - lipgloss.Height(staticPart)
+	if m.renderFooter() != "" {
+		height--
+	}
+	return max(1, height)
+}
+
+// renderFooter summarizes the selected facet key on one line: its count,
+// mean, stdev, range and percentiles. It follows the selection in every view
+// and is empty when nothing is selected.
+func (m model) renderFooter() string {
+	if m.activeFacet == "" || m.showHelp {
+		return ""
+	}
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+	column := m.activeFacetColumn()
+	values := dataSource[column][m.activeFacet]
+	if len(values) == 0 {
+		return ""
+	}
+
+	stats := m.keyStats(values)
+	mean, stdev := m.keyMeanStdev(column, m.activeFacet, values)
+	f := func(v float64) string { return formatFloat(v, m.precision, 2) }
+	footer := fmt.Sprintf("%d:%s  n=%d mean=%s stdev=%s min=%s", column, m.activeFacet, stats.Count, f(mean), f(stdev), f(stats.Min))
+	for _, p := range m.percentiles {
+		footer += fmt.Sprintf(" %s=%s", percentileName(p), f(stats.Percentile(p)))
+	}
+	footer += " max=" + f(stats.Max)
+	footer = m.fitStats(footer, 0)
+	if !m.noColor {
+		footer = lipgloss.NewStyle().Reverse(true).Render(footer)
+	}
+	return footer
+}
+
+// maxScrollOffset returns the largest scroll offset that still fills the screen.
+func (m model) maxScrollOffset() int {
+	contentLines := strings.Count(m.renderContent(), "\n") + 1
+	return max(0, contentLines-m.availableContentHeight(m.renderStatic()))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// -------------------------
+// Main
+// -------------------------
+
+// newModel returns a model with no data yet, set up with the defaults of
+// main's flags. main applies the flags to it; tests use it as it is.
+func newModel() *model {
+	return &model{
+		facetsData:   make(histogram.Histogram),
+		startTime:    time.Now(),
+		barHeight:    10,
+		emptyCell:    "·",
+		markers:      markers{pin: "📌", exclude: "🚫", active: ">"},
+		precision:    -1,
+		sortMode:     sortAuto,
+		sortedKeys:   &sortedKeyCache{},
+		rangeCache:   &globalRangeCache{},
+		statsCache:   &statsCache{},
+		layout:       &screenLayout{},
+		crossMax:     1000,
+		timeLayout:   time.RFC3339,
+		timeBucket:   time.Minute,
+		lines:        make(chan parsedLine, 100),
+		panics:       make(chan goroutinePanic, 1),
+		parseWorkers: 1,
+		delimiter:    "\t",
+		valueColumn:  1,
+		percentiles:  []float64{50, 90, 99},
+		// Defaults for window dimensions; they will be updated on WindowSizeMsg.
+		winWidth:  80,
+		winHeight: 24,
+		// String counting mode
+		stringValues: make(map[string]int),
+		countStrings: true, // Always count strings for any input
+		// Navigation
+		facetPositions:  make(map[string][2]int),
+		activeFacetKeys: make([]string, 0),
+		// Pinning feature
+		pinnedFacets:       make(map[string]bool),
+		pinnedFacetsColumn: make(map[string]int),
+		// Exclude pins
+		excludedFacets:       make(map[string]bool),
+		excludedFacetsColumn: make(map[string]int),
+		filteredData:         make(histogram.Histogram),
+		// Store original lines
+		storedLines: make([]string, 0),
+		keyArrivals: make(map[int]map[string][]time.Time),
+	}
+}
+
+func main() {
+	// The flags default to the model's defaults, and are applied to it below
+	m := newModel()
+	facetFlag := flag.Int("facet", 0, "Facet column (1-indexed) to display; 0 for all facets")
+	statsFlag := flag.Bool("stats", false, "Display mean and stdev instead of a full histogram (v toggles)")
+	heightFlag := flag.Int("height", m.barHeight, "Height of the histogram bars in the single-facet view")
+	logFlag := flag.Bool("log", false, "Use logarithmically spaced bins for skewed distributions")
+	barsFlag := flag.String("bars", "solid", "Histogram bar glyphs: solid, smooth (eighth blocks), or ascii")
+	perFacetScaleFlag := flag.Bool("per-facet-scale", false, "Scale each single-facet panel to its own min/max instead of the global range")
+	globalColorFlag := flag.Bool("global-color", false, "Normalize all-facets colors to the largest bucket across every column instead of per column")
+	densityFlag := flag.Bool("density", false, "Normalize each facet's bins to its own total (relative frequency)")
+	scaleFlag := flag.String("scale", "", "Transform each value before binning: log (or log10), ln, /N to divide by N (e.g. /1e6 for ns to ms), or N to multiply")
+	binsFlag := flag.String("bins", "", "Number of histogram bins, auto to pick from the data, or equalfreq[:N] for bins holding equal shares of the data (default: per view)")
+	yAxisFlag := flag.Bool("y-axis", false, "Show count tick labels to the left of vertical histograms")
+	markerFlag := flag.String("marker", "none", "Mark the mean and/or median bin above histograms: none, mean, median, or both")
+	compactFlag := flag.Bool("compact", false, "Render one sparkline row per facet key in the all-facets view")
+	boxPlotFlag := flag.Bool("boxplot", false, "Render single-facet panels as box plots")
+	horizontalFlag := flag.Bool("horizontal", false, "Render single-facet histograms as horizontal bars, one row per bin")
+	widthFlag := flag.Int("width", 0, "Lay views out for at most N columns, however wide the terminal; 0 uses the full width")
+	topFlag := flag.Int("top", 0, "Show only the N most common values in the string histogram; 0 shows all")
+	maxLinesFlag := flag.Int("max-lines", 0, "Keep only the most recent N lines, dropping older data; 0 keeps everything")
+	promAddrFlag := flag.String("prom-addr", "", "Serve the data as Prometheus histogram metrics at this address (e.g. :9090)")
+	statsdFlag := flag.String("statsd", "", "Periodically send per-key mean, percentile and count gauges to this StatsD HOST:PORT (DogStatsD tags)")
+	statsdPrefixFlag := flag.String("statsd-prefix", "histo.", "Metric name prefix for -statsd")
+	statsdIntervalFlag := flag.Duration("statsd-interval", 10*time.Second, "How often -statsd sends, if the data changed")
+	influxFlag := flag.String("influx", "", "Periodically write per-key statistics as InfluxDB line protocol, appended to this file or POSTed to this http(s) URL")
+	influxIntervalFlag := flag.Duration("influx-interval", 10*time.Second, "How often -influx writes")
+	sortFlag := flag.String("sort", m.sortMode.String(), "Initial facet sort order: auto, mean, count, name, stdev, or numeric (key value); auto sorts all-numeric keys by value and others by mean")
+	stableOrderFlag := flag.Bool("stable-order", false, "Keep keys in the order they were first sorted into as data changes; R re-sorts")
+	svgFlag := flag.String("export-svg", "", "SVG file to write the current view to with S")
+	exportFlag := flag.String("export-json", "", "JSON file to write a snapshot of every key's statistics to with e and on quit")
+	baselineFlag := flag.String("baseline", "", "JSON snapshot (from -export-json) to diff the live statistics against with D")
+	summaryFlag := flag.Bool("summary", false, "Print a plain-text summary of every facet's statistics to stdout on quit")
+	pinsFileFlag := flag.String("pins-file", "", "JSON file to load pins from at startup and save them to with w")
+	headerFlag := flag.Bool("header", false, "Skip the first input line as a header row")
+	delimiterFlag := flag.String("delimiter", m.delimiter, "Column separator, e.g. , for CSV (\\t or tab for a tab)")
+	valueColFlag := flag.Int("value-col", m.valueColumn, "Input column (1-indexed) holding the values; the other columns are facet columns 1, 2, ... in input order")
+	percentilesFlag := flag.String("percentiles", percentileList(m.percentiles), "Comma-separated percentiles shown in the footer and written by -summary, -influx and -statsd")
+	parseWorkersFlag := flag.Int("parse-workers", m.parseWorkers, "Number of goroutines parsing input lines; raise it when input arrives faster than one core can parse")
+	precisionFlag := flag.Int("precision", m.precision, "Decimal places for displayed values (default: 2 for stats, 1 for axis labels)")
+	noLegendFlag := flag.Bool("no-legend", false, "Hide the color legend under the all-facets view (L toggles it)")
+	reverseColorsFlag := flag.Bool("reverse-colors", false, "Reverse the color ramp so the most common bins get the low (cool) end")
+	paletteFlag := flag.String("palette", "spectrum", "Color ramp for the all-facets view, also used to color keys: spectrum, viridis, or cividis (colorblind-safe)")
+	windowFlag := flag.Duration("window", 0, "Only keep data that arrived within this sliding window (e.g. 30s); 0 keeps everything")
+	asciiFlag := flag.Bool("ascii", false, "Use only ASCII characters and no color")
+	noEmojiFlag := flag.Bool("no-emoji", false, "Use ASCII pin and exclude markers instead of emoji (implied by -ascii)")
+	pinMarkerFlag := flag.String("pin-marker", "", "Marker drawn before pinned keys (default 📌, or * with -no-emoji)")
+	excludeMarkerFlag := flag.String("exclude-marker", "", "Marker drawn before excluded keys (default 🚫, or ! with -no-emoji)")
+	activeMarkerFlag := flag.String("active-marker", "", "One-cell marker drawn before the selected key when there is no color (default >)")
+	noColorFlag := flag.Bool("no-color", false, "Disable color styling but keep Unicode glyphs (also set by NO_COLOR)")
+	var pins pinFlag
+	flag.Var(&pins, "pin", "Pin COLUMN:VALUE at startup, so only matching rows are shown (repeatable)")
+	minCountFlag := flag.Int("min-count", 0, "Hide keys with fewer than N values (N shows them, dimmed); 0 shows all")
+	extremesFlag := flag.Bool("extremes", false, "Show the global max and min, and the facet keys they were recorded under, in the header (M toggles)")
+	markPercentileFlag := flag.Float64("mark-percentile", 0, "In the all-facets view, mark the bin holding each key's Pth percentile (e.g. 99) to compare tails; 0 disables")
+	emptyCellFlag := flag.String("empty-cell", "", "One-cell glyph for empty buckets in the all-facets view (default ·, or . with -ascii)")
+	tightCellsFlag := flag.Bool("tight-cells", false, "Draw all-facets buckets two columns wide instead of five, so more fit on narrow terminals")
+	zeroFlag := flag.Bool("zero", false, "For data straddling zero, align bins on zero and draw a labeled zero baseline, coloring negative bins apart")
+	naFlag := flag.String("na", "", "Comma-separated tokens meaning no value (e.g. -,NULL,N/A): counted as missing in the value column, and grouped under «null» in facet columns")
+	crossFlag := flag.String("cross", "", "Facet by the combination of two columns, e.g. 2,3, as an extra column of a|b keys")
+	crossMaxFlag := flag.Int("cross-max", m.crossMax, "Most -cross combinations to keep apart; later ones are counted together as «other»")
+	timeColFlag := flag.Int("time-col", 0, "Facet column (1-indexed) of timestamps to facet by time bucket instead, e.g. to see the values per minute")
+	timeLayoutFlag := flag.String("time-layout", m.timeLayout, "Go time layout of the -time-col timestamps, or unix for epoch seconds")
+	timeBucketFlag := flag.Duration("time-bucket", m.timeBucket, "Size of the -time-col time buckets (e.g. 10s, 1m, 1h)")
+	ignoreColsFlag := flag.String("ignore-cols", "", "Comma-separated facet columns (1-indexed) to never turn into facets, e.g. a free-text column; other columns keep their numbers")
+	distinctFlag := flag.Int("distinct", 0, "Facet column (1-indexed) to only count distinct values of, with a HyperLogLog estimate, instead of drawing per-key histograms")
+	sampleStdevFlag := flag.Bool("sample-stdev", false, "Use the sample standard deviation (dividing by n-1) instead of the population one (dividing by n)")
+	ciFlag := flag.Bool("ci", false, "Also show a 95% confidence interval for the mean (t-based for small samples), as ±half-width")
+	geomeanFlag := flag.Bool("geomean", false, "Also show the geometric mean (n/a for keys with non-positive values)")
+	trimFlag := flag.Float64("trim", 0, "Also show a P%-trimmed mean, dropping the lowest and highest P percent of values (0 < P < 50)")
+	outliersFlag := flag.Bool("outliers", false, "Highlight histogram bins holding outliers (beyond 1.5 IQR from the quartiles) and count them")
+	overflowFlag := flag.Bool("overflow", false, "Show counts of values below (<N) and above (>N) the binned range at the ends of histograms")
+	ewmaFlag := flag.Float64("ewma", 0, "Show exponentially weighted mean and stdev with this smoothing factor (0 < ALPHA < 1; larger tracks recent data more closely); 0 disables")
+	configFlag := flag.String("config", "", "Read default flag values, pins and key bindings from this file (name = value lines, TOML-style); flags on the command line override it")
+	flag.Parse()
+
+	var cfg config
+	if *configFlag != "" {
+		var err error
+		cfg, err = loadConfig(*configFlag)
+		if err == nil {
+			err = cfg.applyFlags()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Honor the NO_COLOR convention (https://no-color.org); ASCII output is
+	// always colorless
+	noColor := *noColorFlag || *asciiFlag || os.Getenv("NO_COLOR") != ""
+
+	keys, err := newKeymap(cfg.keys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *configFlag, err)
+		os.Exit(1)
+	}
+	if *trimFlag < 0 || *trimFlag >= 50 {
+		fmt.Fprintf(os.Stderr, "Error: -trim must be between 0 and 50, got %g\n", *trimFlag)
+		os.Exit(1)
+	}
+	if *widthFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -width must not be negative, got %d\n", *widthFlag)
+		os.Exit(1)
+	}
+	if *topFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -top must not be negative, got %d\n", *topFlag)
+		os.Exit(1)
+	}
+	if *timeColFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -time-col must not be negative, got %d\n", *timeColFlag)
+		os.Exit(1)
+	}
+	if *timeBucketFlag <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: -time-bucket must be positive, got %v\n", *timeBucketFlag)
+		os.Exit(1)
+	}
+	if *crossMaxFlag < 1 {
+		fmt.Fprintf(os.Stderr, "Error: -cross-max must be positive, got %d\n", *crossMaxFlag)
+		os.Exit(1)
+	}
+	if *minCountFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -min-count must not be negative, got %d\n", *minCountFlag)
+		os.Exit(1)
+	}
+	if *markPercentileFlag < 0 || *markPercentileFlag > 100 {
+		fmt.Fprintf(os.Stderr, "Error: -mark-percentile must be between 0 and 100, got %g\n", *markPercentileFlag)
+		os.Exit(1)
+	}
+	if *ewmaFlag < 0 || *ewmaFlag >= 1 {
+		fmt.Fprintf(os.Stderr, "Error: -ewma must be between 0 and 1, got %g\n", *ewmaFlag)
+		os.Exit(1)
+	}
+	bars, err := parseBarStyle(*barsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	binCount, autoBins, equalFreq, err := parseBinsFlag(*binsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	scale, err := parseValueScale(*scaleFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	marker, err := parseMarkerMode(*markerFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	colorPalette, err := parsePalette(*paletteFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	initialSort, err := parseSortMode(*sortFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	emptyCell := *emptyCellFlag
+	if emptyCell == "" {
+		emptyCell = "·"
+		if *asciiFlag {
+			emptyCell = "."
+		}
+	}
+	if runewidth.StringWidth(emptyCell) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: -empty-cell must be one cell wide, got %q\n", emptyCell)
+		os.Exit(1)
+	}
+	naTokens := make(map[string]bool)
+	if *naFlag != "" {
+		for _, token := range strings.Split(*naFlag, ",") {
+			naTokens[token] = true
+		}
+	}
+	ignoredColumns, err := parseColumnList(*ignoreColsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, used := range []struct {
+		flag   string
+		column int
+	}{{"-facet", *facetFlag}, {"-distinct", *distinctFlag}, {"-time-col", *timeColFlag}} {
+		if ignoredColumns[used.column] {
+			fmt.Fprintf(os.Stderr, "Error: %s %d is one of the -ignore-cols columns\n", used.flag, used.column)
+			os.Exit(1)
+		}
+	}
+	delimiter, err := parseDelimiter(*delimiterFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *valueColFlag < 1 {
+		fmt.Fprintf(os.Stderr, "Error: -value-col must be positive, got %d\n", *valueColFlag)
+		os.Exit(1)
+	}
+	percentiles, err := parsePercentiles(*percentilesFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	cross, err := parseCrossFlag(*crossFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	keyMarkers, err := newMarkers(*pinMarkerFlag, *excludeMarkerFlag, *activeMarkerFlag, *noEmojiFlag || *asciiFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	m.facet = *facetFlag
+	m.stats = *statsFlag
+	m.barHeight = *heightFlag
+	m.logScale = *logFlag
+	m.bars = bars
+	m.perFacetScale = *perFacetScaleFlag
+	m.globalColorScale = *globalColorFlag
+	m.density = *densityFlag
+	m.binCount = binCount
+	m.autoBins = autoBins
+	m.equalFreq = equalFreq
+	m.zeroBaseline = *zeroFlag
+	m.markPercentile = *markPercentileFlag
+	m.showExtremes = *extremesFlag
+	m.minCount = *minCountFlag
+	m.valueScale = scale
+	m.emptyCell = emptyCell
+	m.tightCells = *tightCellsFlag
+	m.yAxis = *yAxisFlag
+	m.marker = marker
+	m.compact = *compactFlag
+	m.boxPlot = *boxPlotFlag
+	m.horizontal = *horizontalFlag
+	m.ascii = *asciiFlag
+	m.markers = keyMarkers
+	m.sortMode = initialSort
+	m.noColor = noColor
+	m.palette = colorPalette
+	m.reverseColors = *reverseColorsFlag
+	m.hideLegend = *noLegendFlag
+	m.precision = *precisionFlag
+	m.window = *windowFlag
+	m.maxLines = *maxLinesFlag
+	m.topStrings = *topFlag
+	m.maxWidth = *widthFlag
+	m.pinsFile = *pinsFileFlag
+	m.keys = keys
+	m.ewmaAlpha = *ewmaFlag
+	m.showOverflow = *overflowFlag
+	m.outliers = *outliersFlag
+	m.trim = *trimFlag
+	m.geomean = *geomeanFlag
+	m.confidence = *ciFlag
+	m.sampleStdev = *sampleStdevFlag
+	m.distinctColumn = *distinctFlag
+	m.ignoredColumns = ignoredColumns
+	m.timeColumn = *timeColFlag
+	m.cross = cross
+	m.crossMax = *crossMaxFlag
+	m.timeLayout = *timeLayoutFlag
+	m.timeBucket = *timeBucketFlag
+	m.naTokens = naTokens
+	m.exportPath = *exportFlag
+	m.svgPath = *svgFlag
+	m.parseWorkers = *parseWorkersFlag
+	m.header = *headerFlag
+	m.delimiter = delimiter
+	m.valueColumn = *valueColFlag
+	m.percentiles = percentiles
+
+	if len(pins) > 0 {
+		m.addPins(pins)
+	}
+	if m.pinsFile != "" {
+		if err := m.loadPinsFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var promListener net.Listener
+	if *promAddrFlag != "" {
+		// Listen up front so a bad address is reported before the TUI starts
+		promListener, err = net.Listen("tcp", *promAddrFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		m.prom = &promExporter{}
+		m.prom.update(m)
+	}
+
+	if *statsdFlag != "" {
+		conn, err := net.Dial("udp", *statsdFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer conn.Close()
+		m.statsd = &statsdEmitter{conn: conn, prefix: *statsdPrefixFlag, interval: *statsdIntervalFlag}
+	}
+
+	if *stableOrderFlag {
+		m.stableOrder = &keyOrder{}
+	}
+
+	if *baselineFlag != "" {
+		baseline, err := loadSnapshot(*baselineFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		m.baseline = baseline
+	}
+
+	if *influxFlag != "" {
+		m.influx = &influxWriter{target: *influxFlag, interval: *influxIntervalFlag, last: time.Now()}
+	}
+
+	// A file argument (a regular file or a FIFO) replaces stdin
+	if path := flag.Arg(0); path != "" && path != "-" {
+		input, err := openInput(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		m.input = input
+		m.inputName = path
+	}
+
+	// Update and View run on this goroutine, so a panic in them (or one
+	// passed on from the input goroutines) unwinds through here: restore the
+	// terminal before reporting it
+	p := tea.NewProgram(m, tea.WithMouseCellMotion(), tea.WithoutCatchPanics())
+	defer restoreOnPanic(p)
+	if promListener != nil {
+		// Serve only returns if the listener fails; say so instead of
+		// leaving the metrics to go stale unnoticed
+		go func() {
+			err := http.Serve(promListener, m.prom)
+			p.Send(statusMsg(fmt.Sprintf("Prometheus server stopped: %v", err)))
+		}()
+	}
+	if err := p.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The TUI has torn down, so the summary lands in the scrollback (or a redirect)
+	if *summaryFlag {
+		m.writeSummary(os.Stdout)
+	}
+	if m.exportPath != "" {
+		if err := m.writeSnapshot(m.exportPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// restoreOnPanic, deferred around the program, returns the terminal to its
+// normal state if the program panics, then reports the panic and its stack
+// trace on stderr and exits with status 2, as an uncaught panic would.
+// Bubble Tea's own handler would print it to stdout and carry on to a clean
+// exit, hiding the crash.
+func restoreOnPanic(p *tea.Program) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	p.ReleaseTerminal()
+	stack := debug.Stack()
+	if gp, ok := r.(goroutinePanic); ok {
+		// Report where the background goroutine panicked, not where Update
+		// passed it on
+		r, stack = gp.value, gp.stack
+	}
+	fmt.Fprintf(os.Stderr, "panic: %v\n\n%s", r, stack)
+	os.Exit(2)
+}
+
+// promExporter serves the accumulated data in the Prometheus text exposition
+// format. The text is rebuilt on the Update goroutine and handed to HTTP
+// handlers through an atomic.Value, so handlers never touch the model.
+type promExporter struct {
+	text atomic.Value // string
+	// valid and version record the data version text was built at.
+	valid   bool
+	version int
+}
+
+// update rebuilds the exposition text from the model's unfiltered data, if
+// it changed since the last update. The bins are the ones the views use.
+func (p *promExporter) update(m *model) {
+	if p.valid && p.version == m.dataVersion {
+		return
+	}
+	p.valid, p.version = true, m.dataVersion
+
+	// The export covers all the data, whatever the pins
+	unfiltered := *m
+	unfiltered.isFiltered = false
+	var bins histogram.Binning
+	if gmin, gmax, _, ok := unfiltered.globalStats(); ok {
+		values := unfiltered.allValues()
+		bins = m.binningFor(values, gmin, gmax, m.binCountFor(values, 10, 10))
+	}
+	p.text.Store(unfiltered.prometheusText(bins))
+}
+
+// ServeHTTP writes the latest exposition text.
+func (p *promExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	text, _ := p.text.Load().(string)
+	io.WriteString(w, text)
+}
+
+// promLabelEscaper escapes Prometheus label values.
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusText renders each facet key of the unfiltered data as a
+// Prometheus histogram with the upper edges of bins as buckets, and the facet
+// column and key as labels. A binning without bins (no data yet) gives only
+// the metric's header.
+func (m model) prometheusText(bins histogram.Binning) string {
+	data := m.facetsData
+	var b strings.Builder
+	b.WriteString("# HELP histo_value Distribution of first-column values by facet column and key.\n")
+	b.WriteString("# TYPE histo_value histogram\n")
+	if bins.Count == 0 {
+		return b.String()
+	}
+
+	for _, facet := range data.Columns() {
+		for _, key := range data.Keys(facet) {
+			values := data[facet][key]
+			labels := fmt.Sprintf(`column="%d",key="%s"`, facet, promLabelEscaper.Replace(key))
+			// Buckets are cumulative and include their upper bound. Bins
+			// of a single value all end at it, and a series can only have
+			// one bucket per bound
+			sorted := m.keyStats(values).Sorted
+			for i := 1; i <= bins.Count; i++ {
+				edge := bins.Edge(i)
+				if i > 1 && edge == bins.Edge(i-1) {
+					continue
+				}
+				cumulative := sort.Search(len(sorted), func(j int) bool { return sorted[j] > edge })
+				le := strconv.FormatFloat(edge, 'g', -1, 64)
+				fmt.Fprintf(&b, "histo_value_bucket{%s,le=\"%s\"} %d\n", labels, le, cumulative)
+			}
+			fmt.Fprintf(&b, "histo_value_bucket{%s,le=\"+Inf\"} %d\n", labels, len(values))
+			sum := 0.0
+			for _, v := range values {
+				sum += v
+			}
+			fmt.Fprintf(&b, "histo_value_sum{%s} %s\n", labels, strconv.FormatFloat(sum, 'g', -1, 64))
+			fmt.Fprintf(&b, "histo_value_count{%s} %d\n", labels, len(values))
+		}
+	}
+	return b.String()
+}
 
-					// Check if we've reached maxHeight
-					if maxHeight > 0 && lineCount >= maxHeight {
-						return wrapped.String() + "..."
-					}
+// statsdMaxPacket keeps batched StatsD datagrams under a typical MTU.
+const statsdMaxPacket = 1432
+
+// statsdEmitter sends DogStatsD gauges for each facet key over UDP.
+type statsdEmitter struct {
+	conn     net.Conn
+	prefix   string
+	interval time.Duration
+	last     time.Time
+	// valid and version record the data version last sent; gauges keep
+	// their value, so unchanged data isn't sent again.
+	valid   bool
+	version int
+}
 
-					word = word[width:]
-				}
-			} else {
-				line = word
+// statsdTagEscaper replaces characters that would break DogStatsD tags.
+var statsdTagEscaper = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_", " ", "_")
+
+// emit sends the mean, percentiles and count of every facet key in the unfiltered
+// data as gauges tagged with the facet column and key, packing as many as fit
+// into each datagram, unless the data is unchanged since the last emit. Send
+// errors are ignored: StatsD over UDP is best-effort.
+func (s *statsdEmitter) emit(m *model) {
+	if s.valid && s.version == m.dataVersion {
+		return
+	}
+	s.valid, s.version = true, m.dataVersion
+
+	var packet strings.Builder
+	flush := func() {
+		if packet.Len() > 0 {
+			s.conn.Write([]byte(packet.String()))
+			packet.Reset()
+		}
+	}
+	send := func(line string) {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacket {
+			flush()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+
+	for facet, facetMap := range m.facetsData {
+		for key, values := range facetMap {
+			if len(values) == 0 {
+				continue
 			}
+			stats := m.keyStats(values)
+			tags := fmt.Sprintf("|#column:%d,key:%s", facet, statsdTagEscaper.Replace(key))
+			g := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+			send(s.prefix + "mean:" + g(stats.Mean) + "|g" + tags)
+			for _, p := range m.percentiles {
+				// A dot would nest the name in StatsD's hierarchy
+				name := strings.ReplaceAll(percentileName(p), ".", "_")
+				send(s.prefix + name + ":" + g(stats.Percentile(p)) + "|g" + tags)
+			}
+			send(s.prefix + "count:" + strconv.Itoa(stats.Count) + "|g" + tags)
 		}
 	}
+	flush()
+}
 
-	if line != "" {
-		wrapped.WriteString(line)
+// influxTimeout bounds each -influx POST, so a stuck server can't hold one
+// open forever.
+const influxTimeout = 10 * time.Second
+
+// influxClient sends -influx POSTs.
+var influxClient = &http.Client{Timeout: influxTimeout}
+
+// influxWriter appends InfluxDB line protocol to a file, or POSTs it when
+// the target is an http(s) URL.
+type influxWriter struct {
+	target   string
+	interval time.Duration
+	last     time.Time
+	// posting receives the outcome of the POST in flight, if there is one.
+	posting chan error
+}
+
+// write sends one batch of lines. POSTs run in the background so a slow
+// server can't stall the UI, one at a time: a batch due while the previous
+// one is still in flight is skipped. poll reports how they went.
+func (w *influxWriter) write(lines string) error {
+	if lines == "" {
+		return nil
+	}
+	if strings.HasPrefix(w.target, "http://") || strings.HasPrefix(w.target, "https://") {
+		if w.posting != nil {
+			return nil
+		}
+		posting := make(chan error, 1)
+		w.posting = posting
+		go func() {
+			posting <- postInflux(w.target, lines)
+		}()
+		return nil
 	}
 
-	return wrapped.String()
+	f, err := os.OpenFile(w.target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(lines); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
-// renderSingleFacet builds panels for a single facet column and arranges them in a grid.
-func (m model) renderSingleFacet() string {
+// poll returns the error of the POST in flight once it has finished, or nil
+// if it succeeded or is still running.
+func (w *influxWriter) poll() error {
+	if w.posting == nil {
+		return nil
+	}
+	select {
+	case err := <-w.posting:
+		w.posting = nil
+		return err
+	default:
+		return nil
+	}
+}
+
+// postInflux POSTs lines to url, treating a non-2xx response as an error.
+func postInflux(url, lines string) error {
+	resp, err := influxClient.Post(url, "text/plain; charset=utf-8", strings.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// influxTagEscaper escapes InfluxDB tag values.
+var influxTagEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// influxLines renders the statistics of every facet key as InfluxDB line
+// protocol stamped with now, using the filtered data when pins are active so
+// the series match what is displayed.
+func (m *model) influxLines(now time.Time) string {
 	dataSource := m.facetsData
 	if m.isFiltered {
 		dataSource = m.filteredData
 	}
 
-	facetData, ok := dataSource[m.facet]
-	if !ok {
-		return "Facet not available yet."
+	var b strings.Builder
+	g := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	for facet, facetMap := range dataSource {
+		for key, values := range facetMap {
+			if len(values) == 0 {
+				continue
+			}
+			s := m.keyStats(values)
+			fmt.Fprintf(&b, "histo,column=%d,key=%s mean=%s,stdev=%s,count=%di,min=%s",
+				facet, influxTagEscaper.Replace(key), g(s.Mean), g(s.Stdev), s.Count, g(s.Min))
+			for _, p := range m.percentiles {
+				fmt.Fprintf(&b, ",%s=%s", percentileName(p), g(s.Percentile(p)))
+			}
+			fmt.Fprintf(&b, ",max=%s %d\n", g(s.Max), now.UnixNano())
+		}
 	}
+	return b.String()
+}
 
-	// Build a slice of keys and sort them by descending mean.
-	keys := getSortedFacetKeys(facetData)
+// snapshotKey is the JSON form of one facet key's statistics.
+type snapshotKey struct {
+	Column int           `json:"column"`
+	Key    string        `json:"key"`
+	Count  int           `json:"count"`
+	Mean   snapshotFloat `json:"mean"`
+	Stdev  snapshotFloat `json:"stdev"`
+	P50    snapshotFloat `json:"p50"`
+	P90    snapshotFloat `json:"p90"`
+	P99    snapshotFloat `json:"p99"`
+}
 
-	gmin, gmax, found := m.globalRange()
-	if !found {
-		return "No data yet."
+// snapshotFloat is a statistic in a snapshot. JSON has no NaN or infinities
+// (which -scale or an overflowing sum can produce), so they are written as
+// null and read back as NaN.
+type snapshotFloat float64
+
+// MarshalJSON implements json.Marshaler.
+func (f snapshotFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return []byte("null"), nil
 	}
+	return json.Marshal(v)
+}
 
-	// Constants for consistent panel dimensions
-	const maxKeyWidth = 64 // Maximum width for facet keys before wrapping
-	const maxKeyHeight = 3 // Maximum height for wrapped facet keys
-	const plotWidth = 70   // Width limit for the entire plot
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *snapshotFloat) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*f = snapshotFloat(math.NaN())
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = snapshotFloat(v)
+	return nil
+}
 
-	// Check if any titles wrap to two lines by wrapping all titles first
-	wrappedTitles := make([]string, len(keys))
-	anyWrapped := false
-	for i, key := range keys {
-		displayKey := key
-		if m.pinnedFacets[key] {
-			displayKey = fmt.Sprintf("📌 %s", key)
+// snapshot is the JSON export of every key's statistics; -baseline loads
+// one to diff the live data against.
+type snapshot struct {
+	Time time.Time     `json:"time"`
+	Keys []snapshotKey `json:"keys"`
+}
+
+// takeSnapshot summarizes every key in the data, filtered by the current
+// pins, ordered by column and key.
+func (m *model) takeSnapshot() snapshot {
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
+
+	s := snapshot{Time: time.Now(), Keys: []snapshotKey{}}
+	for facet, facetMap := range dataSource {
+		for key, values := range facetMap {
+			if len(values) == 0 {
+				continue
+			}
+			stats := m.keyStats(values)
+			s.Keys = append(s.Keys, snapshotKey{
+				Column: facet, Key: key, Count: stats.Count,
+				Mean: snapshotFloat(stats.Mean), Stdev: snapshotFloat(stats.Stdev),
+				P50: snapshotFloat(stats.Percentile(50)), P90: snapshotFloat(stats.Percentile(90)),
+				P99: snapshotFloat(stats.Percentile(99)),
+			})
 		}
-		wrappedTitles[i] = wrapText(displayKey, maxKeyWidth, maxKeyHeight)
-		if strings.Contains(wrappedTitles[i], "\n") {
-			anyWrapped = true
+	}
+	sort.Slice(s.Keys, func(i, j int) bool {
+		if s.Keys[i].Column != s.Keys[j].Column {
+			return s.Keys[i].Column < s.Keys[j].Column
+		}
+		return s.Keys[i].Key < s.Keys[j].Key
+	})
+	return s
+}
+
+// currentSnapshot returns takeSnapshot, cached until the data changes, for
+// views that redraw it every frame.
+func (m *model) currentSnapshot() *snapshot {
+	c := m.statsCache
+	if c == nil {
+		s := m.takeSnapshot()
+		return &s
+	}
+	c.sync(m.dataVersion)
+	i := boolIndex(m.isFiltered)
+	if c.snapshots[i] == nil {
+		s := m.takeSnapshot()
+		c.snapshots[i] = &s
+	}
+	return c.snapshots[i]
+}
+
+// writeSnapshot writes a snapshot of the current statistics to path as JSON.
+func (m *model) writeSnapshot(path string) error {
+	data, err := json.MarshalIndent(m.takeSnapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// exportSnapshot writes a snapshot to m.exportPath, reporting the outcome in
+// the status message.
+func (m *model) exportSnapshot() {
+	if m.exportPath == "" {
+		m.statusMessage = "No export file (use -export-json FILE)"
+		return
+	}
+	if err := m.writeSnapshot(m.exportPath); err != nil {
+		m.statusMessage = fmt.Sprintf("Export failed: %v", err)
+		return
+	}
+	m.statusMessage = "Exported snapshot to " + m.exportPath
+}
+
+// loadSnapshot reads a snapshot written by writeSnapshot.
+func loadSnapshot(path string) (*snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading baseline: %w", err)
+	}
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing baseline %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Colors of diff deltas: increases (usually regressions for latency) and
+// decreases.
+const (
+	diffUpColor   = "203"
+	diffDownColor = "76"
+)
+
+// renderDiff compares every key's statistics with the baseline snapshot,
+// showing the change in count, mean and p99. Keys on only one side are
+// flagged as new or gone.
+func (m model) renderDiff() string {
+	current := m.currentSnapshot()
+	type pair struct{ cur, base *snapshotKey }
+	type ref struct {
+		column int
+		key    string
+	}
+	pairs := make(map[ref]*pair)
+	var refs []ref
+	get := func(k snapshotKey) *pair {
+		r := ref{k.Column, k.Key}
+		if pairs[r] == nil {
+			pairs[r] = &pair{}
+			refs = append(refs, r)
 		}
+		return pairs[r]
 	}
-
-	// Create panels with consistent heights
-	var panels []string
-	for i, key := range keys {
-		values := facetData[key]
-		var content string
-		if m.stats {
-			mean := computeMean(values)
-			var variance float64
-			for _, v := range values {
-				variance += (v - mean) * (v - mean)
-			}
-			variance /= float64(len(values))
-			stdev := math.Sqrt(variance)
-			content = fmt.Sprintf("Mean: %.2f\nStd Dev: %.2f\nCount: %d", mean, stdev, len(values))
-		} else {
-			content = createVerticalHistogram(values, gmin, gmax, 10, 10)
+	for i := range current.Keys {
+		get(current.Keys[i]).cur = &current.Keys[i]
+	}
+	for i := range m.baseline.Keys {
+		get(m.baseline.Keys[i]).base = &m.baseline.Keys[i]
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].column != refs[j].column {
+			return refs[i].column < refs[j].column
 		}
+		return refs[i].key < refs[j].key
+	})
 
-		// Use different styles based on active and pinned status
-		var panel string
-		var titleContent string
-
-		// Use the pre-wrapped title
-		titleContent = wrappedTitles[i]
+	// Cells are padded as plain text, then deltas are colored by sign
+	type cell struct {
+		text string
+		sign float64
+	}
+	delta := func(cur, base float64, precision int) cell {
+		return cell{formatDelta(cur-base, base, precision), cur - base}
+	}
+	header := []cell{{"key", 0}, {"count", 0}, {"Δcount", 0}, {"mean", 0}, {"Δmean", 0}, {"p99", 0}, {"Δp99", 0}}
+	if m.ascii {
+		header = []cell{{"key", 0}, {"count", 0}, {"d.count", 0}, {"mean", 0}, {"d.mean", 0}, {"p99", 0}, {"d.p99", 0}}
+	}
+	f := func(v float64) string { return formatFloat(v, m.precision, 2) }
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Diff vs baseline from %s\n", m.baseline.Time.Format("2006-01-02 15:04:05")))
+	for start := 0; start < len(refs); {
+		column := refs[start].column
+		end := start
+		for end < len(refs) && refs[end].column == column {
+			end++
+		}
 
-		// If any title wrapped to two lines, ensure all titles have at least two lines
-		// by adding an extra newline to single-line titles
-		if anyWrapped && !strings.Contains(titleContent, "\n") {
-			titleContent = titleContent + "\n"
+		rows := [][]cell{header}
+		for _, r := range refs[start:end] {
+			p := pairs[r]
+			switch {
+			case p.base == nil:
+				rows = append(rows, []cell{{r.key, 0}, {strconv.Itoa(p.cur.Count), 0}, {"new", 1}, {f(float64(p.cur.Mean)), 0}, {}, {f(float64(p.cur.P99)), 0}, {}})
+			case p.cur == nil:
+				rows = append(rows, []cell{{r.key, 0}, {"0", 0}, {"gone", -1}, {"", 0}, {}, {"", 0}, {}})
+			default:
+				rows = append(rows, []cell{{r.key, 0},
+					{strconv.Itoa(p.cur.Count), 0}, delta(float64(p.cur.Count), float64(p.base.Count), 0),
+					{f(float64(p.cur.Mean)), 0}, delta(float64(p.cur.Mean), float64(p.base.Mean), m.precision),
+					{f(float64(p.cur.P99)), 0}, delta(float64(p.cur.P99), float64(p.base.P99), m.precision)})
+			}
 		}
 
-		// Render the panel with wrapped text
-		if key == m.activeFacet && m.pinnedFacets[key] {
-			// Both active and pinned
-			panel = activePinnedPanelStyle.Render(fmt.Sprintf("%s\n\n%s", titleContent, content))
-		} else if key == m.activeFacet {
-			// Just active
-			panel = activePanelStyle.Render(fmt.Sprintf("%s\n\n%s", titleContent, content))
-		} else if m.pinnedFacets[key] {
-			// Just pinned
-			panel = pinnedPanelStyle.Render(fmt.Sprintf("%s\n\n%s", titleContent, content))
-		} else {
-			// Neither
-			panel = panelStyle.Render(fmt.Sprintf("%s\n\n%s", titleContent, content))
+		widths := make([]int, len(header))
+		for _, row := range rows {
+			for i, c := range row {
+				widths[i] = max(widths[i], runewidth.StringWidth(c.text))
+			}
+		}
+		b.WriteString(fmt.Sprintf("\nFacet %d\n", column))
+		for _, row := range rows {
+			b.WriteString(" ")
+			for i, c := range row {
+				text := " " + c.text
+				if i < len(row)-1 {
+					text = " " + runewidth.FillRight(c.text, widths[i]) + " "
+				}
+				if !m.noColor && c.sign != 0 {
+					color := diffUpColor
+					if c.sign < 0 {
+						color = diffDownColor
+					}
+					text = lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render(text)
+				}
+				b.WriteString(text)
+			}
+			b.WriteString("\n")
 		}
-		panels = append(panels, panel)
+		start = end
 	}
+	return b.String()
+}
 
-	// Calculate grid layout for navigation
-	// Use real panel width to determine columns that fit
-	var panelWidth int
-	if len(panels) > 0 {
-		panelWidth = lipgloss.Width(panels[0])
-	} else {
-		panelWidth = 60 // Default if no panels
+// SVG layout, in pixels: each histogram cell is svgCell square, and text is
+// drawn svgFont high in a monospace font, so svgChar approximates its width.
+const (
+	svgCell = 14
+	svgFont = 11
+	svgChar = 7
+	svgPad  = 16
+)
+
+// xtermColor converts a 256-color terminal code to its usual RGB value.
+func xtermColor(code int) string {
+	switch {
+	case code < 16:
+		basic := []string{
+			"#000000", "#800000", "#008000", "#808000", "#000080", "#800080", "#008080", "#c0c0c0",
+			"#808080", "#ff0000", "#00ff00", "#ffff00", "#0000ff", "#ff00ff", "#00ffff", "#ffffff",
+		}
+		return basic[max(code, 0)]
+	case code < 232:
+		levels := []int{0, 95, 135, 175, 215, 255}
+		code -= 16
+		return fmt.Sprintf("#%02x%02x%02x", levels[code/36], levels[code/6%6], levels[code%6])
 	}
+	gray := 8 + 10*(min(code, 255)-232)
+	return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+}
 
-	columns := max(1, m.winWidth/panelWidth)
-	m.gridColumns = columns
+// svgCanvas collects SVG elements, growing its size to fit them.
+type svgCanvas struct {
+	body          strings.Builder
+	width, height int
+}
 
-	// Create return grid
-	return renderGridLayout(panels, columns)
+func (c *svgCanvas) rect(x, y, w, h int, fill string) {
+	c.body.WriteString(fmt.Sprintf("<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", x, y, w, h, fill))
+	c.width, c.height = max(c.width, x+w), max(c.height, y+h)
 }
 
-// renderGridLayout arranges panels in a grid
-func renderGridLayout(panels []string, columns int) string {
-	if len(panels) == 0 {
-		return ""
+// text draws s with its baseline at y, starting at x or, with anchor "end",
+// ending there.
+func (c *svgCanvas) text(x, y int, s, anchor string) {
+	c.body.WriteString(fmt.Sprintf("<text x=\"%d\" y=\"%d\" text-anchor=\"%s\">%s</text>\n", x, y, anchor, html.EscapeString(s)))
+	right := x
+	if anchor != "end" {
+		right += runewidth.StringWidth(s) * svgChar
 	}
+	c.width, c.height = max(c.width, right), max(c.height, y+svgFont/3)
+}
 
-	// Arrange panels in rows
-	var rows []string
-	for rowIdx := 0; rowIdx < len(panels); rowIdx += columns {
-		end := rowIdx + columns
-		if end > len(panels) {
-			end = len(panels)
-		}
+func (c *svgCanvas) String() string {
+	return fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"%d\">\n", c.width+svgPad, c.height+svgPad, svgFont) +
+		"<rect width=\"100%\" height=\"100%\" fill=\"#ffffff\"/>\n" +
+		c.body.String() + "</svg>\n"
+}
 
-		row := lipgloss.JoinHorizontal(lipgloss.Top, panels[rowIdx:end]...)
-		rows = append(rows, row)
+// svgCellFill returns the fill of a bin cell holding count values, colored
+// as in the terminal against scale.
+func (m model) svgCellFill(count, scale int) string {
+	if count == 0 {
+		return "#eeeeee"
 	}
-
-	return lipgloss.JoinVertical(lipgloss.Top, rows...)
+	return xtermColor(m.palette.color(m.cellIntensity(count, scale)))
 }
 
-// renderMultiFacet renders a summary for all facet columns.
-func (m model) renderMultiFacet() string {
-	var output strings.Builder
+// renderSVG draws the view on screen as an SVG image: the all-facets view
+// as rows of colored bin cells, a facet column as a grid of bar histograms
+// or as a heatmap, and string values as bars. Colors come from the active
+// palette, scaled as in the terminal. The other views are refused.
+func (m model) renderSVG() (string, error) {
+	c := &svgCanvas{}
+	view := m.currentView()
+	switch view {
+	case viewStrings:
+		m.svgStrings(c)
+		return c.String(), nil
+	case viewStacked, viewDistinct, viewCompare, viewDiff:
+		return "", fmt.Errorf("the %s view can't be exported as SVG", view)
+	}
 
 	dataSource := m.facetsData
 	if m.isFiltered {
 		dataSource = m.filteredData
 	}
-
-	// First determine global min/max for consistent bucketing
 	gmin, gmax, found := m.globalRange()
 	if !found {
-		return "No data yet."
+		return "", errors.New("no data to export")
+	}
+	if view == viewMultiFacet {
+		m.svgMultiFacet(c, dataSource, gmin, gmax)
+		return c.String(), nil
 	}
 
-	// Number of buckets for histogram representation
-	const bucketCount = 20
-	bucketSize := (gmax - gmin) / float64(bucketCount)
+	facetData, ok := dataSource[m.facet]
+	if !ok {
+		return "", fmt.Errorf("facet %d has no data", m.facet)
+	}
+	if view == viewHeatmap {
+		m.svgHeatmap(c, facetData, gmin, gmax)
+	} else {
+		m.svgSingleFacet(c, facetData, gmin, gmax)
+	}
+	return c.String(), nil
+}
+
+// svgMultiFacet draws every facet column's keys as rows of bin cells.
+func (m model) svgMultiFacet(c *svgCanvas, dataSource histogram.Histogram, gmin, gmax float64) {
+	allValues := m.allValues()
+	bins := m.binningFor(allValues, gmin, gmax, m.binCountFor(allValues, 20, max(1, (m.renderWidth()-40)/5)))
 
-	// Sort facet numbers for consistent rendering order in summary stats
 	facets := make([]int, 0, len(dataSource))
-	for facet := range dataSource {
+	keyWidth := 0
+	for facet, facetData := range dataSource {
 		facets = append(facets, facet)
+		for key := range facetData {
+			keyWidth = max(keyWidth, runewidth.StringWidth(key))
+		}
 	}
 	sort.Ints(facets)
 
-	// Reset facet positions for navigation
-	m.facetPositions = make(map[string][2]int)
-
-	// Track row position for facet navigation
-	rowPosition := 0
-
-	// Reset active facet keys
-	m.activeFacetKeys = nil
-
-	// If the active facet isn't set yet, initialize it with the first key from sorted keys
-	// This ensures consistent navigation starting point
-	firstFacetKey := ""
+	// As in the terminal, colors are relative to the largest bin of the
+	// column, of every column, or of the key itself with density
+	maxAll := 0
 	for _, facet := range facets {
-		facetData := dataSource[facet]
-		keys := getSortedFacetKeys(facetData)
-		if len(keys) > 0 {
-			firstFacetKey = keys[0]
-			break
+		for _, values := range dataSource[facet] {
+			for _, count := range bins.Counts(values) {
+				maxAll = max(maxAll, count)
+			}
 		}
 	}
 
-	if m.activeFacet == "" && firstFacetKey != "" {
-		m.activeFacet = firstFacetKey
-	}
-
+	left := svgPad + (keyWidth+1)*svgChar
+	y := svgPad
 	for _, facet := range facets {
 		facetData := dataSource[facet]
-		output.WriteString(fmt.Sprintf("Facet %d:\n", facet))
-
-		// Find the max key length across all facets for consistent alignment
-		globalMaxKeyLength := 0
-		for _, facetMap := range dataSource {
-			for key := range facetMap {
-				keyLen := len(key)
-				// Add extra width for pin emoji if this key is pinned
-				if m.pinnedFacets[key] {
-					keyLen += 3 // Width of "📌 " (emoji + space)
-				}
-				if keyLen > globalMaxKeyLength {
-					globalMaxKeyLength = keyLen
-				}
-			}
+		keys := m.visibleFacetKeys(facet, facetData)
+		if len(keys) == 0 {
+			continue
 		}
-		// Ensure we have enough space for buckets
-		if globalMaxKeyLength < 10 {
-			globalMaxKeyLength = 10
+		y += svgFont
+		c.text(svgPad, y, fmt.Sprintf("Facet %d", facet), "start")
+		y += svgFont / 2
+		for i := 0; i <= bins.Count; i += 5 {
+			c.text(left+i*svgCell, y+svgFont, formatFloat(bins.Edge(i), m.precision, 1), "start")
 		}
-		// Use global max length for consistent alignment
-		maxKeyLength := globalMaxKeyLength
-
-		// Build a slice of keys and sort them by descending mean
-		keys := getSortedFacetKeys(facetData)
-
-		// Add to active facet keys for navigation
-		m.activeFacetKeys = append(m.activeFacetKeys, keys...)
-
-		// Calculate max count across all buckets for color normalization
-		maxBucketCount := 0
-		for _, key := range keys {
-			values := facetData[key]
-			buckets := make([]int, bucketCount)
-
-			// Distribute values into buckets
-			for _, v := range values {
-				idx := int((v - gmin) / bucketSize)
-				if idx >= bucketCount {
-					idx = bucketCount - 1
-				} else if idx < 0 {
-					idx = 0
+		y += svgFont + 4
+
+		maxColumn := maxAll
+		if !m.globalColorScale {
+			maxColumn = 0
+			for _, key := range keys {
+				for _, count := range bins.Counts(facetData[key]) {
+					maxColumn = max(maxColumn, count)
 				}
-				buckets[idx]++
-				if buckets[idx] > maxBucketCount {
-					maxBucketCount = buckets[idx]
-				}
-			}
-		}
-
-		// Show bucket scale at the top
-		output.WriteString("  ")
-		output.WriteString(strings.Repeat(" ", maxKeyLength))
-		output.WriteString("  ")
-
-		for i := 0; i < bucketCount; i++ {
-			if i%5 == 0 {
-				val := gmin + float64(i)*bucketSize
-				output.WriteString(fmt.Sprintf("%-5.1f", val))
-			} else {
-				output.WriteString("     ")
 			}
 		}
-		output.WriteString(fmt.Sprintf("%-5.1f\n", gmax))
-
-		// Display colorized histograms for each key
 		for _, key := range keys {
 			values := facetData[key]
-			mean := computeMean(values)
-			var variance float64
-			for _, v := range values {
-				variance += (v - mean) * (v - mean)
-			}
-			variance /= float64(len(values))
-			stdev := math.Sqrt(variance)
-
-			buckets := make([]int, bucketCount)
-
-			// Distribute values into buckets
-			for _, v := range values {
-				idx := int((v - gmin) / bucketSize)
-				if idx >= bucketCount {
-					idx = bucketCount - 1
-				} else if idx < 0 {
-					idx = 0
-				}
-				buckets[idx]++
-			}
-
-			// Format stats
-			stats := fmt.Sprintf("μ=%.2f σ=%.2f n=%d", mean, stdev, len(values))
-
-			// Store position for navigation before styling
-			// Use flat 2D layout - each key gets its own row in this facet
-			m.facetPositions[key] = [2]int{rowPosition, 0}
-
-			// Update active facet position if this is our active facet
-			if key == m.activeFacet {
-				m.activeFacetPos = [2]int{rowPosition, 0}
-			}
-
-			rowPosition++
-
-			// Output the key name with proper padding
-			keyStyle := lipgloss.NewStyle()
-
-			// Different styling based on active/pinned status
-			var formattedKey string
-			if key == m.activeFacet && m.pinnedFacets[key] {
-				// Both active and pinned
-				keyStyle = keyStyle.Foreground(lipgloss.Color("205")).Bold(true).Background(lipgloss.Color("23"))
-				// Emoji 📌 is a multi-byte character but displays as single width
-				formattedKey = keyStyle.Render(fmt.Sprintf("📌 %-*s", maxKeyLength-3, key))
-			} else if key == m.activeFacet {
-				// Just active
-				keyStyle = keyStyle.Foreground(lipgloss.Color("15")).Bold(true).Background(lipgloss.Color("27"))
-				formattedKey = keyStyle.Render(fmt.Sprintf("%-*s", maxKeyLength, key))
-			} else if m.pinnedFacets[key] {
-				// Just pinned
-				keyStyle = keyStyle.Foreground(lipgloss.Color("205"))
-				// Emoji 📌 is a multi-byte character but displays as single width
-				formattedKey = keyStyle.Render(fmt.Sprintf("📌 %-*s", maxKeyLength-3, key))
-			} else {
-				// Neither
-				formattedKey = keyStyle.Render(fmt.Sprintf("%-*s", maxKeyLength, key))
+			counts := bins.Counts(values)
+			scale := m.cellScale(maxColumn, counts)
+			c.text(svgPad, y+svgCell-3, key, "start")
+			for i, count := range counts {
+				c.rect(left+i*svgCell, y, svgCell-1, svgCell-1, m.svgCellFill(count, scale))
 			}
+			mean, stdev := m.keyMeanStdev(facet, key, values)
+			c.text(left+bins.Count*svgCell+svgChar, y+svgCell-3, fmt.Sprintf("mean=%s stdev=%s n=%d",
+				formatFloat(mean, m.precision, 2), formatFloat(stdev, m.precision, 2), len(values)), "start")
+			y += svgCell
+		}
+		y += svgPad
+	}
+}
 
-			output.WriteString(fmt.Sprintf("  %s", formattedKey))
+// svgSingleFacet draws each key of the current facet column as a bar
+// histogram, in a grid of panels.
+func (m model) svgSingleFacet(c *svgCanvas, facetData map[string][]float64, gmin, gmax float64) {
+	allValues := m.allValues()
+	bins := m.binningFor(allValues, gmin, gmax, m.binCountFor(allValues, 10, max(1, (m.renderWidth()-8)/5)))
+	keys := m.visibleFacetKeys(m.facet, facetData)
 
-			// Output histogram with colored squares
-			output.WriteString("  ")
-			for _, count := range buckets {
-				// Calculate color intensity based on logarithmic scale of count
-				if count == 0 {
-					output.WriteString("·    ") // Empty bucket
-				} else {
-					// Use logarithmic scale for better dynamic range
-					logCount := math.Log1p(float64(count)) // log(1+count) to handle count=1 case
-					logMax := math.Log1p(float64(maxBucketCount))
-
-					// Normalize to range 0.0-1.0
-					normalized := logCount / logMax
-
-					// Map to a color spectrum from blue (low) to red (high)
-					// Using a wider range of terminal colors (16-231)
-					// Colors 196-201: red-orange
-					// Colors 202-208: orange-yellow
-					// Colors 40-46: green
-					// Colors 27-33: blue
-
-					var color int
-					switch {
-					case normalized < 0.25:
-						// Blue range (27-33)
-						color = 27 + int(normalized*24)
-					case normalized < 0.5:
-						// Green range (40-46)
-						color = 40 + int((normalized-0.25)*24)
-					case normalized < 0.75:
-						// Yellow range (202-208)
-						color = 202 + int((normalized-0.5)*24)
-					default:
-						// Red range (196-201)
-						color = 196 + int((normalized-0.75)*20)
-					}
+	const barHeight = 120
+	binWidth := 2 * svgCell
+	panelWidth := max(bins.Count*binWidth, 20*svgChar) + 2*svgPad
+	panelHeight := barHeight + 3*svgFont + 3*svgPad
+	columns := max(1, min(len(keys), 3))
 
-					square := lipgloss.NewStyle().
-						Background(lipgloss.Color(fmt.Sprintf("%d", color))).
-						Render(" ")
+	for i, key := range keys {
+		values := facetData[key]
+		x0 := svgPad + (i%columns)*panelWidth
+		y0 := svgPad + (i/columns)*panelHeight
 
-					output.WriteString(square + "    ")
-				}
+		keyBins := bins
+		if m.perFacetScale {
+			kmin, kmax, _ := valueRange(values)
+			keyBins = m.binningFor(values, kmin, kmax, bins.Count)
+		}
+		mean, stdev := m.keyMeanStdev(m.facet, key, values)
+		c.text(x0, y0+svgFont, key, "start")
+		c.text(x0, y0+2*svgFont+4, fmt.Sprintf("mean=%s stdev=%s n=%d",
+			formatFloat(mean, m.precision, 2), formatFloat(stdev, m.precision, 2), len(values)), "start")
+
+		weights := binWeights(keyBins, keyBins.Counts(values), m.density)
+		maxWeight := 0.0
+		for _, w := range weights {
+			maxWeight = math.Max(maxWeight, w)
+		}
+		base := y0 + 2*svgFont + svgPad + barHeight
+		for b, w := range weights {
+			if w == 0 {
+				continue
 			}
-
-			// Output stats after the histogram
-			output.WriteString(" " + stats + "\n")
+			h := max(1, int(w/maxWeight*barHeight))
+			fill := xtermColor(m.palette.color(m.intensity(w / maxWeight)))
+			c.rect(x0+b*binWidth, base-h, binWidth-2, h, fill)
 		}
-		output.WriteString("\n")
+		c.rect(x0, base, keyBins.Count*binWidth, 1, "#888888")
+		c.text(x0, base+svgFont+2, formatFloat(keyBins.Min, m.precision, 1), "start")
+		c.text(x0+keyBins.Count*binWidth, base+svgFont+2, formatFloat(keyBins.Max, m.precision, 1), "end")
 	}
-	return output.String()
 }
 
-// renderColorGradient displays the color gradient used in the visualization
-func renderColorGradient() string {
-	var builder strings.Builder
-
-	// The 4 color ranges used in the application
-	colorRanges := []struct {
-		start int
-		end   int
-	}{
-		{27, 33},
-		{40, 46},
-		{202, 208},
-		{196, 201},
+// svgHeatmap draws each key of the current facet column as a row of bin
+// cells, colored against the largest bin of the column as in the terminal.
+func (m model) svgHeatmap(c *svgCanvas, facetData map[string][]float64, gmin, gmax float64) {
+	keys := m.visibleFacetKeys(m.facet, facetData)
+	keyWidth := 10
+	for _, key := range keys {
+		keyWidth = max(keyWidth, runewidth.StringWidth(key))
 	}
+	allValues := m.allValues()
+	bins := m.binningFor(allValues, gmin, gmax, m.binCountFor(allValues, 20, max(1, (m.renderWidth()-keyWidth-14)/2)))
 
-	// Display each color in the gradient with spacing
-	for _, colorRange := range colorRanges {
-		for color := colorRange.start; color <= colorRange.end; color++ {
-			square := lipgloss.NewStyle().
-				Background(lipgloss.Color(fmt.Sprintf("%d", color))).
-				Render("  ")
-			builder.WriteString(square)
+	rows := make([][]int, len(keys))
+	maxCount := 0
+	for i, key := range keys {
+		rows[i] = bins.Counts(facetData[key])
+		for _, count := range rows[i] {
+			maxCount = max(maxCount, count)
 		}
-		builder.WriteString(" ")
 	}
 
-	return builder.String()
+	left := svgPad + (keyWidth+1)*svgChar
+	y := svgPad + svgFont
+	for i := 0; i <= bins.Count; i += 5 {
+		c.text(left+i*svgCell, y, formatFloat(bins.Edge(i), m.precision, 1), "start")
+	}
+	y += 4
+	for i, key := range keys {
+		c.text(svgPad, y+svgCell-3, key, "start")
+		for b, count := range rows[i] {
+			c.rect(left+b*svgCell, y, svgCell-1, svgCell-1, m.svgCellFill(count, maxCount))
+		}
+		c.text(left+bins.Count*svgCell+svgChar, y+svgCell-3, fmt.Sprintf("n=%d", len(facetData[key])), "start")
+		y += svgCell
+	}
 }
 
-// View renders the complete UI, including scrolling the content.
-func (m model) View() string {
-	header := m.renderHeader()
-
-	// Render instructions
-	instructions := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("242")).
-		Render("a/d: Change Facet | ←→↑↓: Navigate | Enter: Pin | 0: All Facets | j/k: Scroll | q/Ctrl+C: Quit")
-
-	var content string
-	if len(m.stringValues) > 0 {
-		content = m.renderStringHistogram()
-	} else if m.facet != 0 {
-		content = m.renderSingleFacet()
-	} else {
-		content = m.renderMultiFacet()
+// svgStrings draws the string values as horizontal bars, most common first,
+// keeping the -top-strings most common as the terminal does.
+func (m model) svgStrings(c *svgCanvas) {
+	counts, total := m.stringCounts()
+	if m.topStrings > 0 && len(counts) > m.topStrings {
+		counts = counts[:m.topStrings]
 	}
-
-	// Add the color gradient legend only to the multi-facet view
-	if m.facet == 0 && len(m.stringValues) == 0 {
-		content += renderColorGradient()
+	if len(counts) == 0 {
+		return
 	}
 
-	// Combine header/instructions and content.
-	// We'll apply scrolling only to the content portion.
-	staticPart := header + "\n\n" + instructions + "\n\n"
-
-	// Split content into lines.
-	contentLines := strings.Split(content, "\n")
-	// Calculate available height for content.
-	staticHeight := lipgloss.Height(staticPart)
-	availableHeight := m.winHeight - staticHeight
-	if availableHeight < 1 {
-		availableHeight = 1
-	}
-	// Clamp scroll offset.
-	maxScroll := len(contentLines) - availableHeight
-	if maxScroll < 0 {
-		maxScroll = 0
+	const barWidth = 400
+	keyWidth := 0
+	for _, item := range counts {
+		keyWidth = max(keyWidth, runewidth.StringWidth(item.value))
 	}
-	if m.scrollOffset > maxScroll {
-		m.scrollOffset = maxScroll
+	left := svgPad + (keyWidth+1)*svgChar
+	maxCount := counts[0].count
+	y := svgPad
+	for _, item := range counts {
+		w := max(1, item.count*barWidth/maxCount)
+		c.text(svgPad, y+svgCell-3, item.value, "start")
+		c.rect(left, y, w, svgCell-2, xtermColor(m.palette.color(m.intensity(float64(item.count)/float64(maxCount)))))
+		c.text(left+w+svgChar, y+svgCell-3, fmt.Sprintf("%d (%.1f%%)", item.count, float64(item.count)/float64(total)*100), "start")
+		y += svgCell
 	}
-	// Extract the visible portion.
-	visibleContent := strings.Join(contentLines[m.scrollOffset:min(m.scrollOffset+availableHeight, len(contentLines))], "\n")
-
-	return staticPart + visibleContent
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+// exportSVG writes the current view to m.svgPath, reporting the outcome in
+// the status message.
+func (m *model) exportSVG() {
+	if m.svgPath == "" {
+		m.statusMessage = "No SVG file (use -export-svg FILE)"
+		return
 	}
-	return b
-}
-
-func max(a, b int) int {
-	if a > b {
-		return a
+	svg, err := m.renderSVG()
+	if err == nil {
+		err = os.WriteFile(m.svgPath, []byte(svg), 0o644)
 	}
-	return b
+	if err != nil {
+		m.statusMessage = fmt.Sprintf("SVG export failed: %v", err)
+		return
+	}
+	m.statusMessage = "Exported view to " + m.svgPath
 }
 
-// -------------------------
-// Main
-// -------------------------
-
-func main() {
-	facetFlag := flag.Int("facet", 0, "Facet column (1-indexed) to display; 0 for all facets")
-	statsFlag := flag.Bool("stats", false, "Display mean and stdev instead of a full histogram")
-	flag.Parse()
+// writeSummary writes a plain-text table of each facet key's statistics,
+// respecting the active pins and excludes.
+func (m *model) writeSummary(w io.Writer) {
+	dataSource := m.facetsData
+	if m.isFiltered {
+		dataSource = m.filteredData
+	}
 
-	m := &model{
-		facetsData:    make(map[int]map[string][]float64),
-		totalLogCount: 0,
-		startTime:     time.Now(),
-		facet:         *facetFlag,
-		stats:         *statsFlag,
-		lines:         make(chan string, 100),
-		// Defaults for window dimensions; they will be updated on WindowSizeMsg.
-		winWidth:  80,
-		winHeight: 24,
-		// String counting mode
-		stringValues: make(map[string]int),
-		countStrings: true, // Always count strings for any input
-		// Navigation
-		facetPositions:  make(map[string][2]int),
-		activeFacetKeys: make([]string, 0),
-		// Grid dimensions
-		gridColumns: 0,
-		gridRows:    0,
-		// Pinning feature
-		pinnedFacets:       make(map[string]bool),
-		pinnedFacetsColumn: make(map[string]int),
-		filteredData:       make(map[int]map[string][]float64),
-		isFiltered:         false,
-		// Store original lines
-		storedLines: make([]string, 0),
+	fmt.Fprintf(w, "Total lines: %d\n", m.totalLogCount)
+	if len(m.pinnedFacets) > 0 || len(m.excludedFacets) > 0 {
+		var filters []string
+		for value := range m.pinnedFacets {
+			filters = append(filters, fmt.Sprintf("%d:%s", m.pinnedFacetsColumn[value], value))
+		}
+		for value := range m.excludedFacets {
+			filters = append(filters, fmt.Sprintf("!%d:%s", m.excludedFacetsColumn[value], value))
+		}
+		sort.Strings(filters)
+		fmt.Fprintf(w, "Filters: %s\n", strings.Join(filters, ", "))
 	}
 
-	p := tea.NewProgram(m)
-	if err := p.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	facets := dataSource.Columns()
+
+	f := func(v float64) string { return formatFloat(v, m.precision, 2) }
+	for _, facet := range facets {
+		fmt.Fprintf(w, "\nFacet %d\n", facet)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		header := "key\tcount\tmean\tstdev\tmin\t"
+		for _, p := range m.percentiles {
+			header += percentileName(p) + "\t"
+		}
+		fmt.Fprintln(tw, header+"max\t")
+		for _, key := range getSortedFacetKeys(dataSource[facet], nil, m.sortMode, m.sortReverse) {
+			values := dataSource[facet][key]
+			if len(values) == 0 {
+				continue
+			}
+			s := m.keyStats(values)
+			row := fmt.Sprintf("%s\t%d\t%s\t%s\t%s\t", key, s.Count, f(s.Mean), f(s.Stdev), f(s.Min))
+			for _, p := range m.percentiles {
+				row += f(s.Percentile(p)) + "\t"
+			}
+			fmt.Fprintln(tw, row+f(s.Max)+"\t")
+		}
+		tw.Flush()
 	}
 }
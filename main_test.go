@@ -0,0 +1,985 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dtkav/histo/histogram"
+	"github.com/mattn/go-runewidth"
+)
+
+// newTestModel returns a model reading input, set up as main sets one up
+// with the default flags, on a larger window.
+func newTestModel(input string) *model {
+	m := newModel()
+	m.input = strings.NewReader(input)
+	m.winWidth, m.winHeight = 160, 60
+	return m
+}
+
+// run starts m reading its input and ticks it until the input is exhausted.
+func run(t testing.TB, m *model) {
+	t.Helper()
+	m.Init()
+	deadline := time.Now().Add(5 * time.Second)
+	for m.lines != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("input was not exhausted")
+		}
+		m.Update(tickMsg{})
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHelpBeforeData(t *testing.T) {
+	m := newTestModel("")
+	m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("?")})
+	if got := m.renderContent(); !strings.HasPrefix(got, "Keys\n") {
+		t.Errorf("? with no data shows %q, want the help", got)
+	}
+}
+
+func TestProcessInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		setup  func(*model)
+		facets histogram.Histogram
+		total  int
+	}{
+		{
+			name:   "one facet column",
+			input:  "10\ta\n20\tb\n30\ta\n",
+			facets: histogram.Histogram{1: {"a": {10, 30}, "b": {20}}},
+			total:  3,
+		},
+		{
+			name:  "two facet columns",
+			input: "1.5\tsea\t/api\n2.5\tams\t/api\n",
+			facets: histogram.Histogram{
+				1: {"sea": {1.5}, "ams": {2.5}},
+				2: {"/api": {1.5, 2.5}},
+			},
+			total: 2,
+		},
+		{
+			name:   "blank lines are skipped",
+			input:  "1\ta\n\n   \n2\ta\n",
+			facets: histogram.Histogram{1: {"a": {1, 2}}},
+			total:  2,
+		},
+		{
+			name:   "values without facets are counted",
+			input:  "1\n2\n",
+			facets: histogram.Histogram{},
+			total:  2,
+		},
+		{
+			name:   "strings are counted but not stored",
+			input:  "GET\t/a\nPOST\t/a\n3\t/a\n",
+			facets: histogram.Histogram{1: {"/a": {3}}},
+			total:  3,
+		},
+		{
+			name:   "header row is skipped",
+			input:  "latency\tregion\n7\tsea\n",
+			setup:  func(m *model) { m.header = true },
+			facets: histogram.Histogram{1: {"sea": {7}}},
+			total:  1,
+		},
+		{
+			name:   "comma delimiter",
+			input:  "1,a,x\n2,b,x\n",
+			setup:  func(m *model) { m.delimiter = "," },
+			facets: histogram.Histogram{1: {"a": {1}, "b": {2}}, 2: {"x": {1, 2}}},
+			total:  2,
+		},
+		{
+			name:   "value column",
+			input:  "GET\t/a\t10\t200\nPUT\t/b\t20\t500\n30\n",
+			setup:  func(m *model) { m.valueColumn = 3 },
+			facets: histogram.Histogram{1: {"GET": {10}, "PUT": {20}}, 2: {"/a": {10}, "/b": {20}}, 3: {"200": {10}, "500": {20}}},
+			total:  3,
+		},
+		{
+			name:  "value column with eviction",
+			input: "a\t1\nb\t2\nc\t3\n",
+			setup: func(m *model) {
+				m.valueColumn = 2
+				m.maxLines = 2
+			},
+			facets: histogram.Histogram{1: {"b": {2}, "c": {3}}},
+			total:  2,
+		},
+		{
+			name:   "NaN and infinities are counted as strings",
+			input:  "NaN\ta\n+Inf\ta\n-infinity\ta\n4\ta\n",
+			facets: histogram.Histogram{1: {"a": {4}}},
+			total:  4,
+		},
+		{
+			name:  "values a scale overflows are skipped",
+			input: "1e300\ta\n2\ta\n",
+			setup: func(m *model) {
+				m.valueScale, _ = parseValueScale("1e10")
+			},
+			facets: histogram.Histogram{1: {"a": {2e10}}},
+			total:  2,
+		},
+		{
+			name:   "parse workers keep input order",
+			input:  strings.Repeat("1\ta\n2\tb\n3\ta\n", 200),
+			setup:  func(m *model) { m.parseWorkers = 8 },
+			facets: histogram.Histogram{1: {"a": repeated([]float64{1, 3}, 200), "b": repeated([]float64{2}, 200)}},
+			total:  600,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(tt.input)
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			run(t, m)
+			if !reflect.DeepEqual(m.facetsData, tt.facets) {
+				t.Errorf("facetsData = %v, want %v", m.facetsData, tt.facets)
+			}
+			if m.totalLogCount != tt.total {
+				t.Errorf("totalLogCount = %d, want %d", m.totalLogCount, tt.total)
+			}
+		})
+	}
+}
+
+// repeated returns n copies of values, one after another.
+func repeated(values []float64, n int) []float64 {
+	var out []float64
+	for i := 0; i < n; i++ {
+		out = append(out, values...)
+	}
+	return out
+}
+
+// BenchmarkReadInput measures how fast readInput parses lines and hands them
+// to the model, with one parse worker and with a pool of eight.
+func BenchmarkReadInput(b *testing.B) {
+	const lineCount = 100000
+	var input strings.Builder
+	for i := 0; i < lineCount; i++ {
+		fmt.Fprintf(&input, "%d.%03d\tregion-%d\t/api/v1/resource/%d\tinstance-%d\n", i%2000, i%1000, i%12, i%50, i%7)
+	}
+	text := input.String()
+
+	for _, workers := range []int{1, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(text)))
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				m := &model{lines: make(chan parsedLine, 100), parseWorkers: workers, delimiter: "\t"}
+				go m.readInput(strings.NewReader(text))
+				for range m.lines {
+				}
+			}
+			b.ReportMetric(float64(lineCount*b.N)/time.Since(start).Seconds(), "lines/s")
+		})
+	}
+}
+
+func TestCrossKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		crossMax int
+		maxLines int
+		want     map[string][]float64
+	}{
+		{
+			name:  "short line is padded with null",
+			input: "1\tsea\t200\n2\tams\n",
+			want:  map[string][]float64{"sea|200": {1}, "ams|«null»": {2}},
+		},
+		{
+			name:     "combinations past the cap share other",
+			input:    "1\ta\tx\n2\tb\tx\n3\tc\tx\n4\ta\tx\n",
+			crossMax: 2,
+			want:     map[string][]float64{"a|x": {1, 4}, "b|x": {2}, "«other»": {3}},
+		},
+		{
+			// b|y arrives while a|x holds the only place, and keeps sharing
+			// «other» while it has stored lines; once a|x and b|y are
+			// evicted, c|z gets the place
+			name:     "evicted combinations free their place",
+			input:    "1\ta\tx\n2\ta\tx\n3\tb\ty\n4\tb\ty\n5\tc\tz\n",
+			crossMax: 1,
+			maxLines: 2,
+			want:     map[string][]float64{"«other»": {4}, "c|z": {5}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(tt.input)
+			m.cross = [2]int{1, 2}
+			if tt.crossMax > 0 {
+				m.crossMax = tt.crossMax
+			}
+			m.maxLines = tt.maxLines
+			run(t, m)
+			if m.crossColumn != 3 {
+				t.Fatalf("crossColumn = %d, want 3", m.crossColumn)
+			}
+			if got := m.facetsData[3]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cross column = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigValue(t *testing.T) {
+	tests := []struct {
+		in, value, rest string
+		err             bool
+	}{
+		{in: `"a b" # note`, value: "a b", rest: " # note"},
+		{in: `"tab\tand \"quote\""`, value: "tab\tand \"quote\""},
+		{in: `'C:\path'`, value: `C:\path`},
+		{in: `12.5, 3`, value: "12.5", rest: ", 3"},
+		{in: `true#comment`, value: "true", rest: "#comment"},
+		{in: `"open`, err: true},
+		{in: `'open`, err: true},
+		{in: ``, err: true},
+	}
+	for _, tt := range tests {
+		value, rest, err := parseConfigValue(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseConfigValue(%q) = %q, want an error", tt.in, value)
+			}
+			continue
+		}
+		if err != nil || value != tt.value || rest != tt.rest {
+			t.Errorf("parseConfigValue(%q) = %q, %q, %v; want %q, %q", tt.in, value, rest, err, tt.value, tt.rest)
+		}
+	}
+}
+
+func TestParseConfigArray(t *testing.T) {
+	tests := []struct {
+		in     string
+		values []string
+		rest   string
+		err    bool
+	}{
+		{in: `[]`, values: nil},
+		{in: `["2:/api", '3:sea', 4] # pins`, values: []string{"2:/api", "3:sea", "4"}, rest: " # pins"},
+		{in: `[ "a,b" , "c]" ]`, values: []string{"a,b", "c]"}},
+		{in: `"a"`, err: true},
+		{in: `["a" "b"]`, err: true},
+		{in: `["a",`, err: true},
+	}
+	for _, tt := range tests {
+		values, rest, err := parseConfigArray(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseConfigArray(%q) = %q, want an error", tt.in, values)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(values, tt.values) || rest != tt.rest {
+			t.Errorf("parseConfigArray(%q) = %q, %q, %v; want %q, %q", tt.in, values, rest, err, tt.values, tt.rest)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "histo.toml")
+	text := `# latency setup
+bins = "auto"   # picked from the data
+height = 14
+
+delimiter = ","
+percentiles = [50, 99.9]
+pins = ["2:/api/orders", "1:sea"]
+keys.quit = ["Q", "ctrl+q"]
+`
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []configSetting{
+		{2, "bins", "auto"},
+		{3, "height", "14"},
+		{5, "delimiter", ","},
+		{6, "percentiles", "50,99.9"},
+		{7, "pin", "2:/api/orders"},
+		{7, "pin", "1:sea"},
+	}
+	if !reflect.DeepEqual(cfg.settings, want) {
+		t.Errorf("settings = %v, want %v", cfg.settings, want)
+	}
+	if keys := cfg.keys["quit"]; !reflect.DeepEqual(keys, []string{"Q", "ctrl+q"}) {
+		t.Errorf("keys.quit = %q", keys)
+	}
+
+	for _, bad := range []string{"bins\n", `bins = "auto" extra` + "\n", "pins = \"2:/api\"\n"} {
+		if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfig(path); err == nil {
+			t.Errorf("loadConfig accepted %q", bad)
+		}
+	}
+}
+
+func TestConfigUnknownSetting(t *testing.T) {
+	cfg := config{path: "histo.toml", settings: []configSetting{{1, "no-such-setting", "1"}}}
+	err := cfg.applyFlags()
+	if err == nil || !strings.Contains(err.Error(), `histo.toml:1: unknown setting "no-such-setting"`) {
+		t.Errorf("applyFlags() = %v, want an unknown setting error", err)
+	}
+}
+
+func TestPromExporter(t *testing.T) {
+	m := newTestModel("1\ta\n1\ta\n1\ta\n1\ta\n1\ta\n1\ta\n1\ta\n1\ta\n2\tb\n100\tb\n")
+	m.equalFreq = true
+	run(t, m)
+
+	p := &promExporter{}
+	p.update(m)
+	text, _ := p.text.Load().(string)
+	// Equal-frequency edges (about 1.2, 11.8 and 100), not the equal-width
+	// 10.9, 20.8, ...
+	for _, want := range []string{`key="b",le="1.2`, `key="b",le="11.7`, `histo_value_bucket{column="1",key="b",le="100"} 2`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("exposition text lacks %s:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, `le="10.9"`) {
+		t.Errorf("exposition text has equal-width buckets:\n%s", text)
+	}
+
+	// Unchanged data isn't rebuilt
+	p.text.Store("stale")
+	p.update(m)
+	if text, _ := p.text.Load().(string); text != "stale" {
+		t.Errorf("update rebuilt unchanged data")
+	}
+	m.processLine("3\ta")
+	p.update(m)
+	if text, _ := p.text.Load().(string); text == "stale" {
+		t.Errorf("update didn't rebuild after new data")
+	}
+
+	// Bins of a single value all end at it, but get one bucket
+	m = newTestModel("5\ta\n5\ta\n")
+	run(t, m)
+	p = &promExporter{}
+	p.update(m)
+	text, _ = p.text.Load().(string)
+	if n := strings.Count(text, `le="5"`); n != 1 {
+		t.Errorf("single-value data has %d le=\"5\" buckets, want 1:\n%s", n, text)
+	}
+}
+
+// ansiEscape matches the SGR sequences lipgloss styles text with.
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestKeyStatsCache(t *testing.T) {
+	m := newTestModel("3\ta\n1\ta\n2\tb\n")
+	run(t, m)
+	values := m.facetsData[1]["a"]
+	first := m.keyStats(values)
+	if !reflect.DeepEqual(first.Sorted, []float64{1, 3}) {
+		t.Fatalf("keyStats sorted = %v, want [1 3]", first.Sorted)
+	}
+	if again := m.keyStats(values); &again.Sorted[0] != &first.Sorted[0] {
+		t.Error("keyStats re-sorted unchanged data")
+	}
+	if all := m.allValues(); &all[0] != &m.allValues()[0] {
+		t.Error("allValues rebuilt unchanged data")
+	}
+
+	m.processLine("2\ta")
+	if got := m.keyStats(m.facetsData[1]["a"]); got.Count != 3 || got.Mean != 2 {
+		t.Errorf("after a new line keyStats = %+v, want count 3, mean 2", got)
+	}
+	if got := len(m.allValues()); got != 4 {
+		t.Errorf("after a new line allValues has %d values, want 4", got)
+	}
+}
+
+// recordingConn records what is written to it.
+type recordingConn struct {
+	net.Conn
+	writes []string
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.writes = append(c.writes, string(b))
+	return len(b), nil
+}
+
+func TestStatsdEmit(t *testing.T) {
+	m := newTestModel("1\ta\n3\ta\n")
+	m.percentiles = []float64{50, 99.9}
+	run(t, m)
+	conn := &recordingConn{}
+	s := &statsdEmitter{conn: conn, prefix: "histo."}
+	s.emit(m)
+	want := "histo.mean:2|g|#column:1,key:a\nhisto.p50:2|g|#column:1,key:a\nhisto.p99_9:2.998|g|#column:1,key:a\nhisto.count:2|g|#column:1,key:a"
+	if len(conn.writes) != 1 || conn.writes[0] != want {
+		t.Fatalf("emit sent %q, want %q", conn.writes, want)
+	}
+
+	// Gauges keep their value, so unchanged data isn't sent again
+	s.emit(m)
+	if len(conn.writes) != 1 {
+		t.Errorf("emit resent unchanged data: %q", conn.writes[1:])
+	}
+	m.processLine("5\ta")
+	s.emit(m)
+	if len(conn.writes) != 2 {
+		t.Errorf("emit didn't send new data")
+	}
+}
+
+func TestInfluxPost(t *testing.T) {
+	release := make(chan struct{})
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	w := &influxWriter{target: server.URL}
+	if err := w.write("histo mean=1\n"); err != nil {
+		t.Fatal(err)
+	}
+	// A batch due while one is in flight is skipped
+	if err := w.write("histo mean=2\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.poll(); err != nil {
+		t.Errorf("poll of a running POST = %v, want nil", err)
+	}
+	close(release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	var err error
+	for err == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		err = w.poll()
+	}
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("poll after a failed POST = %v, want the 503", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("server got %d POSTs, want 1", n)
+	}
+}
+
+func TestEwmaEvictionAndSort(t *testing.T) {
+	// A key that is evicted entirely starts its moving statistics afresh
+	m := newTestModel("100\ta\n1\tb\n5\ta\n")
+	m.ewmaAlpha = 0.5
+	m.maxLines = 1
+	run(t, m)
+	if mean, _ := m.keyMeanStdev(1, "a", m.facetsData[1]["a"]); mean != 5 {
+		t.Errorf("mean of a re-added key = %g, want 5", mean)
+	}
+	if s := m.ewma[1]["b"]; s != nil {
+		t.Errorf("evicted key b kept its moving statistics: %+v", *s)
+	}
+
+	// Sorting by mean uses the moving mean that is displayed: a's recent
+	// values are high though its plain mean is lower than b's
+	m = newTestModel("1\ta\n1\ta\n1\ta\n100\ta\n50\tb\n50\tb\n")
+	m.ewmaAlpha = 0.9
+	run(t, m)
+	if got := m.sortedFacetKeys(1, m.facetsData[1]); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("keys sorted by moving mean = %v, want [a b]", got)
+	}
+}
+
+func TestKeyEntropy(t *testing.T) {
+	even := map[string][]float64{"a": {1}, "b": {2}}
+	if entropy, normalized := keyEntropy(even); entropy != 1 || normalized != 1 {
+		t.Errorf("two even keys: entropy %g, %g of max, want 1, 1", entropy, normalized)
+	}
+	// An empty (excluded) key doesn't lower the maximum
+	even["c"] = nil
+	if _, normalized := keyEntropy(even); normalized != 1 {
+		t.Errorf("with an empty key: %g of max, want 1", normalized)
+	}
+	if entropy, normalized := keyEntropy(map[string][]float64{"a": {1, 2}}); entropy != 0 || normalized != 0 {
+		t.Errorf("one key: entropy %g, %g of max, want 0, 0", entropy, normalized)
+	}
+}
+
+// panickingReader panics on the first read.
+type panickingReader struct{}
+
+func (panickingReader) Read([]byte) (int, error) { panic("read failed") }
+
+func TestReadInputPanic(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			m := newTestModel("")
+			m.input = panickingReader{}
+			m.parseWorkers = workers
+			m.Init()
+
+			// The reader's panic comes out of Update, where restoreOnPanic
+			// is waiting for it
+			defer func() {
+				if p, ok := recover().(goroutinePanic); !ok || p.value != "read failed" {
+					t.Errorf("Update panicked with %v, want the reader's panic", p)
+				}
+			}()
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				m.Update(tickMsg{})
+				time.Sleep(time.Millisecond)
+			}
+			t.Error("Update did not panic")
+		})
+	}
+}
+
+func TestTimeBucketKey(t *testing.T) {
+	tests := []struct {
+		raw    string
+		layout string
+		bucket time.Duration
+		want   string
+	}{
+		{"2024-12-31T23:59:30Z", time.RFC3339, time.Minute, "2024-12-31 23:59"},
+		{"2025-01-01T00:00:10Z", time.RFC3339, time.Minute, "2025-01-01 00:00"},
+		{"2025-01-01T00:00:10Z", time.RFC3339, 5 * time.Second, "2025-01-01 00:00:10"},
+		// An offset is converted, so the key names the same instant as the
+		// UTC-aligned bucket it was truncated to
+		{"2025-01-01T02:30:00+02:00", time.RFC3339, time.Hour, "2025-01-01 00:00"},
+		{"2025-01-01T02:30:00+02:00", time.RFC3339, 24 * time.Hour, "2025-01-01"},
+		{"1735689600", "unix", time.Minute, "2025-01-01 00:00"},
+	}
+	for _, tt := range tests {
+		m := newModel()
+		m.timeLayout, m.timeBucket = tt.layout, tt.bucket
+		if got, ok := m.timeBucketKey(tt.raw); !ok || got != tt.want {
+			t.Errorf("timeBucketKey(%q) with %v buckets = %q, %v, want %q", tt.raw, tt.bucket, got, ok, tt.want)
+		}
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		setup   func(*model)
+		want    string // in the SVG
+		wantErr string
+	}{
+		{"all facets", "1\ta\n2\tb\n", nil, ">a</text>", ""},
+		{"facet", "1\ta\n2\tb\n", func(m *model) { m.facet = 1 }, ">mean=", ""},
+		{"heatmap", "1\ta\n2\tb\n", func(m *model) { m.facet, m.heatmap = 1, true }, ">n=1</text>", ""},
+		{"strings", "GET\nGET\nPOST\n", nil, ">2 (66.7%)</text>", ""},
+		{"stacked", "1\ta\n2\tb\n", func(m *model) { m.facet, m.stacked = 1, true }, "", "the stacked view can't be exported as SVG"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(tt.input)
+			run(t, m)
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			svg, err := m.renderSVG()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("renderSVG error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !strings.Contains(svg, tt.want) {
+				t.Errorf("renderSVG = %q, %v; want it to contain %q", svg, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotNonFinite(t *testing.T) {
+	// The sum overflows, so the mean is +Inf
+	m := newTestModel("1e308\ta\n1e308\ta\n")
+	run(t, m)
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := m.writeSnapshot(path); err != nil {
+		t.Fatalf("writeSnapshot: %v", err)
+	}
+	s, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatalf("loadSnapshot: %v", err)
+	}
+	if len(s.Keys) != 1 || !math.IsNaN(float64(s.Keys[0].Mean)) || s.Keys[0].P50 != 1e308 {
+		t.Errorf("loaded %+v, want a NaN mean and a p50 of 1e308", s.Keys)
+	}
+
+	if m.currentSnapshot() != m.currentSnapshot() {
+		t.Error("currentSnapshot was rebuilt without the data changing")
+	}
+}
+
+func TestStringHistogramFitsWidth(t *testing.T) {
+	m := newTestModel("GET\nGET\nPOST\n")
+	m.winWidth = 60
+	run(t, m)
+
+	for _, line := range strings.Split(m.renderStringHistogram(), "\n") {
+		if !strings.Contains(line, "%") {
+			continue // not a bar
+		}
+		if w := runewidth.StringWidth(line); w > m.winWidth {
+			t.Errorf("line is %d wide, over the %d-wide window: %q", w, m.winWidth, line)
+		}
+	}
+}
+
+func TestSwitchFacetKeepsSelection(t *testing.T) {
+	m := newTestModel("1\ta\tx\n2\tb\ty\n3\tc\tz\n")
+	m.facet = 1
+	run(t, m)
+
+	key := func(k string) { m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}) }
+	m.jumpToFacet(true)
+	selected := m.activeFacet
+	key("d")
+	if m.facet != 2 {
+		t.Fatalf("after d, facet %d, want 2", m.facet)
+	}
+	key("a")
+	if m.activeFacet != selected {
+		t.Errorf("after d and a, selected %q, want %q", m.activeFacet, selected)
+	}
+}
+
+func TestFacetNumberKeys(t *testing.T) {
+	m := newTestModel("1\ta\n")
+	run(t, m)
+	// Leave a gap below the highest column
+	m.facetsData[3] = map[string][]float64{"x": {1}}
+
+	key := func(k string) { m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}) }
+	key("2")
+	if m.facet != 0 || m.statusMessage != "No facet column 2" {
+		t.Errorf("after 2, facet %d and status %q, want 0 and No facet column 2", m.facet, m.statusMessage)
+	}
+	key("3")
+	if m.facet != 3 {
+		t.Errorf("after 3, facet %d, want 3", m.facet)
+	}
+}
+
+func TestMultiFacetWideKeyAlignment(t *testing.T) {
+	keys := []string{"東京", "🚀x", "café", "sea", "ソウル特別市"}
+	var input strings.Builder
+	for i, key := range keys {
+		fmt.Fprintf(&input, "%d\t%s\n%d\t%s\n", i+1, key, 10*(i+1), key)
+	}
+	m := newTestModel(input.String())
+	m.noColor = true
+	run(t, m)
+
+	// Cells are a fixed width, so the stats after them line up only if the
+	// keys before them are padded to the same display width
+	columns := make(map[string]int)
+	for _, row := range strings.Split(ansiEscape.ReplaceAllString(m.renderMultiFacet(), ""), "\n") {
+		stats := strings.Index(row, "μ=")
+		if stats < 0 {
+			continue
+		}
+		for _, key := range keys {
+			if strings.Contains(row[:stats], key) {
+				columns[key] = runewidth.StringWidth(row[:stats])
+			}
+		}
+	}
+	if len(columns) != len(keys) {
+		t.Fatalf("found rows for %v, want all of %v", columns, keys)
+	}
+	for _, key := range keys {
+		if columns[key] != columns[keys[0]] {
+			t.Errorf("stats for %q start at column %d, want %d as for %q", key, columns[key], columns[keys[0]], keys[0])
+		}
+	}
+}
+
+func TestSplitAtWidth(t *testing.T) {
+	tests := []struct {
+		s          string
+		width      int
+		head, tail string
+	}{
+		{"東京都庁", 5, "東京", "都庁"},
+		{"東京都庁", 4, "東京", "都庁"},
+		{"ééééé", 3, "ééé", "éé"},
+		{"naïve", 10, "naïve", ""},
+		// A rune wider than width still goes in head, so callers progress
+		{"東", 1, "東", ""},
+	}
+	for _, tt := range tests {
+		head, tail := splitAtWidth(tt.s, tt.width)
+		if head != tt.head || tail != tt.tail {
+			t.Errorf("splitAtWidth(%q, %d) = %q, %q; want %q, %q", tt.s, tt.width, head, tail, tt.head, tt.tail)
+		}
+	}
+}
+
+func TestWrapTextNonASCII(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+	}{
+		{"résumé-café-crème-brûlée", 8},
+		{"東京都 大阪府庁舎ビル 札幌", 6},
+		{"Ünïcödé façade über-long-naïve-identifier", 7},
+		{"東京都庁舎ビル", 5},
+	}
+	for _, tt := range tests {
+		wrapped := wrapText(tt.text, tt.width, 0)
+		if !utf8.ValidString(wrapped) {
+			t.Errorf("wrapText(%q, %d) = %q, invalid UTF-8", tt.text, tt.width, wrapped)
+		}
+		for _, line := range strings.Split(wrapped, "\n") {
+			if w := runewidth.StringWidth(line); w > tt.width {
+				t.Errorf("wrapText(%q, %d) has line %q of width %d", tt.text, tt.width, line, w)
+			}
+		}
+		// Only spaces become line breaks; nothing is lost
+		if got, want := strings.Join(strings.Fields(wrapped), ""), strings.Join(strings.Fields(tt.text), ""); got != want {
+			t.Errorf("wrapText(%q, %d) = %q, lost text", tt.text, tt.width, wrapped)
+		}
+	}
+
+	// Past maxHeight lines, the rest is elided
+	if got, want := wrapText("東京都庁舎ビル", 4, 2), "東京\n都庁\n..."; got != want {
+		t.Errorf("wrapText with maxHeight 2 = %q, want %q", got, want)
+	}
+}
+
+func TestVerticalHistogramValueBelowRange(t *testing.T) {
+	// A stale range, as when values arrive between computing it and drawing
+	b := histogram.NewBinning(10, 20, 5, false)
+	m := newTestModel("")
+	out := createVerticalHistogram(histogram.Summarize([]float64{2, 15, 25}, false), b, m.histogramOptions(4))
+	if !strings.Contains(out, "11.0") || !strings.Contains(out, "19.0") {
+		t.Errorf("histogram lacks its bin labels:\n%s", out)
+	}
+}
+
+func TestHeaderCategoricalColumn(t *testing.T) {
+	input := "method\tpath\nGET\t/a\nPOST\t/a\nGET\t/b\n"
+	tests := []struct {
+		name    string
+		header  bool
+		strings map[string]int
+		total   int
+	}{
+		{"with -header", true, map[string]int{"GET": 2, "POST": 1}, 3},
+		{"without -header", false, map[string]int{"method": 1, "GET": 2, "POST": 1}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(input)
+			m.header = tt.header
+			run(t, m)
+			if !reflect.DeepEqual(m.stringValues, tt.strings) {
+				t.Errorf("stringValues = %v, want %v", m.stringValues, tt.strings)
+			}
+			if m.totalLogCount != tt.total {
+				t.Errorf("totalLogCount = %d, want %d", m.totalLogCount, tt.total)
+			}
+		})
+	}
+}
+
+func TestSortedFacetKeysNumeric(t *testing.T) {
+	mixed := map[string][]float64{
+		"500": {1}, "200": {9}, "404": {5}, "30": {2}, "1e3": {3}, "other": {100},
+	}
+	codes := map[string][]float64{"500": {1}, "200": {9}, "30": {2}}
+	tests := []struct {
+		name    string
+		data    map[string][]float64
+		mode    sortMode
+		reverse bool
+		want    []string
+	}{
+		// By value, not by name ("30" before "200"); non-numeric keys last
+		{"numeric, mixed keys", mixed, sortByNumeric, false, []string{"30", "200", "404", "500", "1e3", "other"}},
+		{"numeric, reversed", mixed, sortByNumeric, true, []string{"other", "1e3", "500", "404", "200", "30"}},
+		// Auto sorts a column with a non-numeric key by mean
+		{"auto, mixed keys", mixed, sortAuto, false, []string{"other", "200", "404", "1e3", "30", "500"}},
+		{"mean, mixed keys", mixed, sortByMean, false, []string{"other", "200", "404", "1e3", "30", "500"}},
+		// and all-numeric keys by value, but mean still sorts them by mean
+		{"auto, all numeric", codes, sortAuto, false, []string{"30", "200", "500"}},
+		{"mean, all numeric", codes, sortByMean, false, []string{"200", "30", "500"}},
+		{"count, all numeric", codes, sortByCount, false, []string{"200", "30", "500"}},
+	}
+	for _, tt := range tests {
+		if got := getSortedFacetKeys(tt.data, nil, tt.mode, tt.reverse); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: getSortedFacetKeys = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// s cycles from auto through every other mode, mean included
+	seen := make(map[sortMode]bool)
+	for mode := sortAuto.next(); !seen[mode]; mode = mode.next() {
+		seen[mode] = true
+	}
+	if len(seen) != int(sortModeCount) {
+		t.Errorf("cycling from auto reached %d modes, want %d", len(seen), sortModeCount)
+	}
+}
+
+func TestNASentinels(t *testing.T) {
+	naTokens := map[string]bool{"-": true, "NULL": true, "N/A": true}
+	tests := []struct {
+		token   string
+		missing int
+		strings map[string]int
+		facets  histogram.Histogram
+	}{
+		{"-", 1, map[string]int{}, histogram.Histogram{1: {nullKey: {5}, "ams": {7}}}},
+		{"NULL", 1, map[string]int{}, histogram.Histogram{1: {nullKey: {5}, "ams": {7}}}},
+		{"N/A", 1, map[string]int{}, histogram.Histogram{1: {nullKey: {5}, "ams": {7}}}},
+		// Tokens that aren't listed are ordinary strings and keys
+		{"NA", 0, map[string]int{"NA": 1}, histogram.Histogram{1: {"NA": {5}, "ams": {7}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			// The token as a value, then as a facet key
+			m := newTestModel(tt.token + "\tsea\n5\t" + tt.token + "\n7\tams\n")
+			m.naTokens = naTokens
+			run(t, m)
+			if m.missingCount != tt.missing {
+				t.Errorf("missingCount = %d, want %d", m.missingCount, tt.missing)
+			}
+			if !reflect.DeepEqual(m.stringValues, tt.strings) {
+				t.Errorf("stringValues = %v, want %v", m.stringValues, tt.strings)
+			}
+			if !reflect.DeepEqual(m.facetsData, tt.facets) {
+				t.Errorf("facetsData = %v, want %v", m.facetsData, tt.facets)
+			}
+			if m.totalLogCount != 3 {
+				t.Errorf("totalLogCount = %d, want 3", m.totalLogCount)
+			}
+		})
+	}
+}
+
+// benchModel returns a model holding lines lines of a value and two facet
+// columns, with 10 and 7 keys.
+func benchModel(lines int) *model {
+	m := newTestModel("")
+	for i := 0; i < lines; i++ {
+		m.processLine(fmt.Sprintf("%d\tr%d\ts%d", i%1000, i%10, i%7))
+	}
+	return m
+}
+
+// BenchmarkAddPin measures adding a second pin with an existing one keeping
+// 10% of 200k lines: narrowing the filtered data, and reprocessing every
+// stored line as before.
+func BenchmarkAddPin(b *testing.B) {
+	for _, narrowing := range []bool{true, false} {
+		name := "narrow"
+		if !narrowing {
+			name = "reprocess"
+		}
+		b.Run(name, func(b *testing.B) {
+			m := benchModel(200000)
+			m.pinnedFacets["r3"] = true
+			m.pinnedFacetsColumn["r3"] = 1
+			m.refreshFilter(false)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.pinnedFacets["s2"] = true
+				m.pinnedFacetsColumn["s2"] = 2
+				m.refreshFilter(narrowing)
+
+				b.StopTimer()
+				delete(m.pinnedFacets, "s2")
+				delete(m.pinnedFacetsColumn, "s2")
+				m.refreshFilter(false)
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// BenchmarkSortedFacetKeys measures listing the keys of 7 columns of 500
+// keys each over 200k lines, as each render and navigation step does: with
+// the sorted key cache, and re-sorting every time as before.
+func BenchmarkSortedFacetKeys(b *testing.B) {
+	for _, cached := range []bool{true, false} {
+		name := "cached"
+		if !cached {
+			name = "uncached"
+		}
+		b.Run(name, func(b *testing.B) {
+			m := newTestModel("")
+			for i := 0; i < 200000; i++ {
+				m.processLine(fmt.Sprintf("%d\ta%d\tb%d\tc%d\td%d\te%d\tf%d\tg%d", i%1000,
+					i%500, (i+1)%500, (i+2)%500, (i+3)%500, (i+4)%500, (i+5)%500, (i+6)%500))
+			}
+			if !cached {
+				m.sortedKeys = nil
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for facet, facetData := range m.facetsData {
+					m.visibleFacetKeys(facet, facetData)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGlobalRange measures adding a line and reading the global range
+// with 200k lines loaded, as every tick followed by a render does: with the
+// range maintained as lines arrive, and rescanning every value as before.
+func BenchmarkGlobalRange(b *testing.B) {
+	for _, incremental := range []bool{true, false} {
+		name := "incremental"
+		if !incremental {
+			name = "rescan"
+		}
+		b.Run(name, func(b *testing.B) {
+			m := benchModel(200000)
+			if !incremental {
+				m.rangeCache = nil
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.processLine(fmt.Sprintf("%d\tr%d\ts%d", i%1000, i%10, i%7))
+				m.globalStats()
+			}
+		})
+	}
+}